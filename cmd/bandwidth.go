@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type bandwidthLimiter struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time
+}
+
+type throttledConn struct {
+	net.Conn
+	limiter *bandwidthLimiter
+
+	// the deadlines are mirrored so a paced read or write gives up with them
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+	moved         chan struct{} // closed whenever a deadline changes
+}
+
+var scanLimiter *bandwidthLimiter
+
+var bandwidthUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"gbps", 1e9 / 8},
+	{"mbps", 1e6 / 8},
+	{"kbps", 1e3 / 8},
+	{"bps", 1.0 / 8},
+	{"gb/s", 1 << 30},
+	{"mb/s", 1 << 20},
+	{"kb/s", 1 << 10},
+	{"b/s", 1},
+}
+
+// parseBandwidth converts e.g. 5mbps (bits) or 512kb/s (bytes) to bytes per second.
+func parseBandwidth(value string) (float64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	for _, unit := range bandwidthUnits {
+		if number, found := strings.CutSuffix(value, unit.suffix); found {
+			rate, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+			if err != nil || rate <= 0 {
+				break
+			}
+			return rate * unit.bytes, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid bandwidth: %s (e.g. 5mbps, 800kbps or 1mb/s)", value)
+}
+
+func setupBandwidthLimit() error {
+	if globalFlagMaxBandwidth == "" {
+		return nil
+	}
+
+	rate, err := parseBandwidth(globalFlagMaxBandwidth)
+	if err != nil {
+		return err
+	}
+
+	scanLimiter = &bandwidthLimiter{rate: rate}
+
+	return nil
+}
+
+// reserve books n bytes on a shared schedule so the combined rate stays
+// under the limit, and returns when they may go.
+func (l *bandwidthLimiter) reserve(n int) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+
+	return at
+}
+
+// wait blocks until n bytes fit under the limit, or ctx ends.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(time.Until(l.reserve(n))):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pace waits for n bytes' slot like wait, but bounded by the conn's read or
+// write deadline, which is also how a cancelled host interrupts it.
+func (c *throttledConn) pace(n int, read bool) error {
+	if n <= 0 {
+		return nil
+	}
+
+	at := c.limiter.reserve(n)
+	for {
+		c.mu.Lock()
+		deadline, moved := c.writeDeadline, c.moved
+		if read {
+			deadline = c.readDeadline
+		}
+		c.mu.Unlock()
+
+		wake := at
+		if !deadline.IsZero() && deadline.Before(at) {
+			wake = deadline
+		}
+
+		select {
+		case <-time.After(time.Until(wake)):
+			if wake.Equal(at) {
+				return nil
+			}
+			return os.ErrDeadlineExceeded
+		case <-moved:
+		}
+	}
+}
+
+func (c *throttledConn) setDeadlines(read bool, write bool, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if read {
+		c.readDeadline = t
+	}
+	if write {
+		c.writeDeadline = t
+	}
+	if c.moved != nil {
+		close(c.moved)
+	}
+	c.moved = make(chan struct{})
+}
+
+func (c *throttledConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if paceErr := c.pace(n, true); err == nil {
+		err = paceErr
+	}
+	return n, err
+}
+
+func (c *throttledConn) Write(b []byte) (int, error) {
+	if err := c.pace(len(b), false); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *throttledConn) SetDeadline(t time.Time) error {
+	c.setDeadlines(true, true, t)
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *throttledConn) SetReadDeadline(t time.Time) error {
+	c.setDeadlines(true, false, t)
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *throttledConn) SetWriteDeadline(t time.Time) error {
+	c.setDeadlines(false, true, t)
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func throttleConn(conn net.Conn) net.Conn {
+	if scanLimiter == nil {
+		return conn
+	}
+	return &throttledConn{Conn: conn, limiter: scanLimiter}
+}
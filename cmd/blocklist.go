@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type blocklistFeed struct {
+	name string
+	nets []*net.IPNet
+}
+
+var defaultBlocklists = []string{
+	"https://www.spamhaus.org/drop/drop.txt",
+	"https://raw.githubusercontent.com/firehol/blocklist-ipsets/master/firehol_level1.netset",
+}
+
+var blocklistFeeds []blocklistFeed
+
+func blocklistName(source string) string {
+	name := path.Base(source)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// parseBlocklist reads one IP or CIDR per line, ignoring ; and # comments.
+func parseBlocklist(data []byte) []*net.IPNet {
+	var nets []*net.IPNet
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, ";#"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		entry := fields[0]
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+
+		if _, ipnet, err := net.ParseCIDR(entry); err == nil {
+			nets = append(nets, ipnet)
+		}
+	}
+
+	return nets
+}
+
+// fetchBlocklist reads local feeds directly and keeps remote ones in the data
+// cache for a day, falling back to a stale copy when the download fails.
+func fetchBlocklist(source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+
+	dir, err := dataCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	cached := filepath.Join(dir, "blocklists", blocklistName(source)+".txt")
+
+	if stat, err := os.Stat(cached); err == nil && (globalFlagOffline || time.Since(stat.ModTime()) < 24*time.Hour) {
+		return os.ReadFile(cached)
+	}
+	if globalFlagOffline {
+		return nil, fmt.Errorf("not cached, run once without --offline")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(source)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err = fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	if err != nil {
+		if data, cacheErr := os.ReadFile(cached); cacheErr == nil {
+			return data, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cached), 0755); err == nil {
+		writeFileAtomic(cached, data)
+	}
+
+	return data, nil
+}
+
+func loadBlocklists() error {
+	sources := globalFlagBlocklists
+	if len(sources) == 0 {
+		sources = defaultBlocklists
+	}
+
+	for _, source := range sources {
+		data, err := fetchBlocklist(source)
+		if err != nil {
+			return fmt.Errorf("blocklist %s: %w", source, err)
+		}
+		blocklistFeeds = append(blocklistFeeds, blocklistFeed{name: blocklistName(source), nets: parseBlocklist(data)})
+	}
+
+	return nil
+}
+
+func blocklistMatches(ip string) []string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil
+	}
+
+	var listed []string
+	for _, feed := range blocklistFeeds {
+		for _, ipnet := range feed.nets {
+			if ipnet.Contains(parsed) {
+				listed = append(listed, feed.name)
+				break
+			}
+		}
+	}
+
+	return listed
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed data/cdn-providers.json
+var embeddedCDNProviders []byte
+
+type cdnProvider struct {
+	name   string
+	cnames []string
+	nets   []*net.IPNet
+}
+
+var (
+	cdnProvidersOnce sync.Once
+	cdnProviders     []cdnProvider
+	cnameCache       sync.Map
+)
+
+func loadCDNProviders() []cdnProvider {
+	cdnProvidersOnce.Do(func() {
+		var dataset map[string]struct {
+			CNAMEs []string `json:"cnames"`
+			Ranges []string `json:"ranges"`
+		}
+		if err := json.Unmarshal(loadDataset("cdn-providers.json"), &dataset); err != nil {
+			json.Unmarshal(embeddedCDNProviders, &dataset)
+		}
+
+		for name, entry := range dataset {
+			provider := cdnProvider{name: name}
+			for _, cname := range entry.CNAMEs {
+				provider.cnames = append(provider.cnames, strings.ToLower(strings.Trim(cname, ".")))
+			}
+			for _, cidr := range entry.Ranges {
+				if _, ipnet, err := net.ParseCIDR(cidr); err == nil {
+					provider.nets = append(provider.nets, ipnet)
+				}
+			}
+			cdnProviders = append(cdnProviders, provider)
+		}
+		sort.Slice(cdnProviders, func(i, j int) bool { return cdnProviders[i].name < cdnProviders[j].name })
+	})
+	return cdnProviders
+}
+
+// detectCDN names the CDN in front of a result and what gave it away:
+// its response headers, the address falling in a published range, or the
+// host's CNAME pointing into the provider's domain.
+func detectCDN(hostCtx context.Context, host string, ipStr string, headers map[string]string) (provider string, source string) {
+	providers := loadCDNProviders()
+
+	for _, rule := range fingerprintRules {
+		if rule.match(headers) && knownCDN(providers, rule.name) {
+			return rule.name, "header"
+		}
+	}
+
+	if ip := net.ParseIP(ipStr); ip != nil {
+		for _, p := range providers {
+			for _, ipnet := range p.nets {
+				if ipnet.Contains(ip) {
+					return p.name, "ip"
+				}
+			}
+		}
+	}
+
+	cname := lookupCNAME(hostCtx, host)
+	for _, p := range providers {
+		for _, suffix := range p.cnames {
+			if cname == suffix || strings.HasSuffix(cname, "."+suffix) {
+				return p.name, "cname"
+			}
+		}
+	}
+
+	return "", ""
+}
+
+func knownCDN(providers []cdnProvider, name string) bool {
+	for _, p := range providers {
+		if p.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupCNAME returns the canonical name of host, once per host since every
+// port and address of it shares the answer. It is empty for IP literals and
+// failed lookups.
+func lookupCNAME(hostCtx context.Context, host string) string {
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+	if cname, ok := cnameCache.Load(host); ok {
+		return cname.(string)
+	}
+
+	lookupCtx, cancel := context.WithTimeout(hostCtx, time.Duration(directFlagTimeoutDNS)*time.Second)
+	defer cancel()
+
+	cname, err := net.DefaultResolver.LookupCNAME(lookupCtx, host)
+	if err != nil {
+		if lookupCtx.Err() != nil {
+			// not cached so the next port tries again
+			return ""
+		}
+		cname = ""
+	}
+	cname = strings.ToLower(strings.TrimSuffix(cname, "."))
+	cnameCache.Store(host, cname)
+
+	return cname
+}
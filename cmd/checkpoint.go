@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ayanrajpoot10/bugscanx-go/pkg/queuescanner"
+)
+
+// checkpointPath names the checkpoint after the command, its parameters and
+// its task list, so --resume only picks up a run of the same scan.
+func checkpointPath(tasks []string) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "checkpoints")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(scanParams + "\n" + strings.Join(tasks, "\n")))
+	return filepath.Join(dir, activeCommand+"-"+hex.EncodeToString(sum[:])[:16]+".txt"), nil
+}
+
+func readCheckpoint(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	done := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			done[line] = true
+		}
+	}
+
+	return done, scanner.Err()
+}
+
+// startScan runs qs over tasks, checkpointing finished hosts so an
+// interrupted scan can be continued with --resume.
+func startScan(qs *queuescanner.QueueScanner, tasks []string, outputFile string) {
+	startScanSource(qs, tasks, nil, 0, "", outputFile)
+}
+
+// startScanSource is startScan with more tasks generated lazily by source
+// after tasks, e.g. the addresses of a large CIDR. sourceKey stands in for
+// the generated tasks when naming the checkpoint.
+func startScanSource(qs *queuescanner.QueueScanner, tasks []string, source iter.Seq[string], sourceTotal int, sourceKey string, outputFile string) {
+	if shardCount > 1 {
+		offset := len(tasks)
+		tasks = shardTasks(tasks)
+		if source != nil {
+			source = shardSource(offset, source)
+			sourceTotal = shardTotal(offset, sourceTotal)
+			sourceKey += " " + globalFlagShard
+		}
+	}
+
+	keys := tasks
+	if source != nil {
+		keys = append(slices.Clip(tasks), sourceKey)
+	}
+	// without --resume the checkpoint is only a convenience, so a scan on a
+	// read-only or HOME-less system runs without one
+	path, err := checkpointPath(keys)
+	if err != nil {
+		if globalFlagResume {
+			fatal(err)
+		}
+		fmt.Printf("running without a checkpoint: %v\n\n", err)
+		path = ""
+	}
+
+	tasks = skipRecent(tasks)
+	existing := existingHosts()
+	tasks = skipExisting(tasks, existing)
+
+	var done map[string]bool
+	if globalFlagResume {
+		done, err = readCheckpoint(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		var remaining []string
+		for _, task := range tasks {
+			if !done[task] {
+				remaining = append(remaining, task)
+			}
+		}
+
+		// everything in the checkpoint came from tasks or source
+		skipped := len(tasks) - len(remaining)
+		if source != nil {
+			skipped = len(done)
+			if sourceTotal >= 0 {
+				sourceTotal = max(sourceTotal-(len(done)-(len(tasks)-len(remaining))), 0)
+			}
+		}
+		if skipped > 0 {
+			fmt.Printf("resuming, skipping %d hosts finished before the interruption\n\n", skipped)
+		}
+		tasks = remaining
+	} else if path != "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fatal(err)
+		}
+	}
+
+	qs.SetOptions(tasks, outputFile, globalFlagStatInterval)
+	if globalFlagKnownFirst {
+		qs.AddPriority(1, knownGood(tasks)...)
+	}
+	if source != nil {
+		recent := recentlyProbed()
+		if len(recent) > 0 || len(existing) > 0 {
+			sourceTotal = -1
+		}
+		qs.SetSource(func(yield func(string) bool) {
+			for task := range source {
+				if done[task] || recent[task] || existing[task] {
+					continue
+				}
+				if !yield(task) {
+					return
+				}
+			}
+		}, sourceTotal)
+	}
+	if path != "" {
+		if err := qs.SetCheckpoint(path); err != nil {
+			if globalFlagResume {
+				fatal(err)
+			}
+			fmt.Printf("running without a checkpoint: %v\n\n", err)
+		}
+	}
+	qs.Start()
+}
+
+// startStream runs qs over hosts as source produces them. Piped input can't
+// be replayed, so streamed scans skip the checkpoint.
+func startStream(qs *queuescanner.QueueScanner, source iter.Seq[string], outputFile string) {
+	recent := recentlyProbed()
+	existing := existingHosts()
+
+	qs.SetOptions(nil, outputFile, globalFlagStatInterval)
+	qs.SetSource(func(yield func(string) bool) {
+		for host := range shardSource(0, source) {
+			if recent[host] || existing[host] {
+				continue
+			}
+			if !yield(host) {
+				return
+			}
+		}
+	}, -1)
+	qs.Start()
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorBlue   = "\033[34m"
+	colorCyan   = "\033[36m"
+)
+
+var (
+	colorOnce    sync.Once
+	colorEnabled bool
+)
+
+func useColor() bool {
+	colorOnce.Do(func() {
+		_, noColor := os.LookupEnv("NO_COLOR")
+		colorEnabled = !globalFlagNoColor && !noColor && term.IsTerminal(int(os.Stdout.Fd()))
+	})
+	return colorEnabled
+}
+
+func colorize(line string, color string) string {
+	if color == "" || !useColor() {
+		return line
+	}
+	return color + line + colorReset
+}
+
+func colorStatus(line string, status int) string {
+	switch {
+	case status == 101:
+		return colorize(line, colorCyan)
+	case status >= 200 && status < 300:
+		return colorize(line, colorGreen)
+	case status >= 300 && status < 400:
+		return colorize(line, colorYellow)
+	}
+	return line
+}
+
+func colorTLS(line string) string {
+	return colorize(line, colorBlue)
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dnsEntry is one cached lookup. ready is closed once ips and err are set,
+// so concurrent lookups of the same name share a single query.
+type dnsEntry struct {
+	ready   chan struct{}
+	ips     []net.IP
+	err     error
+	expires time.Time
+}
+
+// dnsLookupTimeout bounds a shared lookup, which can't use any one
+// caller's context since the others are waiting on it too.
+const dnsLookupTimeout = 10 * time.Second
+
+var (
+	dnsCacheMu sync.Mutex
+	dnsCache   = make(map[string]*dnsEntry)
+)
+
+// lookupIP resolves host through the process-wide cache. network is ip, ip4
+// or ip6 as for net.Resolver.LookupIP. Only answers and NXDOMAIN are cached,
+// timeouts and other transient failures are retried on the next lookup.
+func lookupIP(ctx context.Context, network string, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return filterIPs(network, []net.IP{ip}), nil
+	}
+	if globalFlagDNSCacheTTL <= 0 {
+		return net.DefaultResolver.LookupIP(ctx, network, host)
+	}
+
+	key := strings.ToLower(host)
+
+	dnsCacheMu.Lock()
+	entry := dnsCache[key]
+	if entry != nil {
+		select {
+		case <-entry.ready:
+			if time.Now().After(entry.expires) {
+				entry = nil
+			}
+		default:
+		}
+	}
+	if entry == nil {
+		entry = &dnsEntry{ready: make(chan struct{})}
+		dnsCache[key] = entry
+		dnsCacheMu.Unlock()
+
+		go resolveEntry(key, entry)
+	} else {
+		dnsCacheMu.Unlock()
+	}
+
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return filterIPs(network, entry.ips), nil
+}
+
+func resolveEntry(key string, entry *dnsEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, key)
+	for _, addr := range addrs {
+		entry.ips = append(entry.ips, addr.IP)
+	}
+	entry.err = err
+	entry.expires = time.Now().Add(globalFlagDNSCacheTTL)
+
+	// only NXDOMAIN is worth remembering, never a timeout
+	var dnsErr *net.DNSError
+	if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		dnsCacheMu.Lock()
+		if dnsCache[key] == entry {
+			delete(dnsCache, key)
+		}
+		dnsCacheMu.Unlock()
+	}
+
+	close(entry.ready)
+}
+
+func filterIPs(network string, ips []net.IP) []net.IP {
+	if network == "ip" {
+		return ips
+	}
+
+	filtered := make([]net.IP, 0, len(ips))
+	for _, ip := range ips {
+		if (ip.To4() != nil) == (network == "ip4") {
+			filtered = append(filtered, ip)
+		}
+	}
+	return filtered
+}
+
+// resolveAddress swaps the hostname in address for its cached IPs so dials
+// skip the resolver. Addresses that already hold an IP come back unchanged.
+func resolveAddress(ctx context.Context, network string, address string) ([]string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil || globalFlagDNSCacheTTL <= 0 {
+		return []string{address}, nil
+	}
+
+	family := "ip"
+	switch {
+	case strings.HasSuffix(network, "4"):
+		family = "ip4"
+	case strings.HasSuffix(network, "6"):
+		family = "ip6"
+	}
+
+	ips, err := lookupIP(ctx, family, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address", Name: host, IsNotFound: true}
+	}
+
+	addresses := make([]string, len(ips))
+	for i, ip := range ips {
+		addresses[i] = net.JoinHostPort(ip.String(), port)
+	}
+	return addresses, nil
+}
@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type enrichment map[string]string
+
+type rdapEntity struct {
+	Roles      []string        `json:"roles"`
+	VcardArray json.RawMessage `json:"vcardArray"`
+	Entities   []rdapEntity    `json:"entities"`
+}
+
+type rdapNetwork struct {
+	Name         string       `json:"name"`
+	Handle       string       `json:"handle"`
+	Country      string       `json:"country"`
+	StartAddress string       `json:"startAddress"`
+	EndAddress   string       `json:"endAddress"`
+	Entities     []rdapEntity `json:"entities"`
+}
+
+type rdapCall struct {
+	done chan struct{}
+	info enrichment
+}
+
+type rdapCacheEntry struct {
+	start net.IP
+	end   net.IP
+	info  enrichment
+}
+
+var (
+	rdapMu      sync.Mutex
+	rdapCache   []rdapCacheEntry
+	rdapByIP    = make(map[string]enrichment)
+	rdapPending = make(map[string]*rdapCall)
+	rdapLimiter *bandwidthLimiter
+	rdapClient  = &http.Client{Timeout: 10 * time.Second}
+)
+
+func validateEnrich() error {
+	for i, source := range globalFlagEnrich {
+		switch source {
+		case "whois", "rdap":
+			globalFlagEnrich[i] = "whois"
+		case "blocklist":
+		default:
+			return fmt.Errorf("invalid enrich source: %s (expected whois or blocklist)", source)
+		}
+	}
+
+	if enrichEnabled("blocklist") {
+		if err := loadBlocklists(); err != nil {
+			return err
+		}
+	}
+
+	if globalFlagRDAPRate <= 0 {
+		return fmt.Errorf("invalid rdap rate: %v (must be above 0)", globalFlagRDAPRate)
+	}
+
+	// public RDAP servers ban clients that burst, so lookups share one pacer
+	rdapLimiter = &bandwidthLimiter{rate: globalFlagRDAPRate}
+
+	return nil
+}
+
+func enrichEnabled(source string) bool {
+	for _, s := range globalFlagEnrich {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// enrichIP gathers the optional --enrich details for a result IP.
+func enrichIP(ip string) enrichment {
+	if net.ParseIP(ip) == nil || len(globalFlagEnrich) == 0 {
+		return nil
+	}
+
+	info := enrichment{}
+	if enrichEnabled("whois") {
+		for key, value := range rdapLookup(ip) {
+			info[key] = value
+		}
+	}
+	if enrichEnabled("blocklist") {
+		if listed := blocklistMatches(ip); len(listed) > 0 {
+			info["blocklist"] = strings.Join(listed, ",")
+		}
+	}
+
+	return info
+}
+
+func (e enrichment) suffix() string {
+	var parts []string
+
+	if e["netname"] != "" || e["org"] != "" {
+		whois := e["netname"]
+		if e["org"] != "" {
+			whois += " (" + e["org"]
+			if e["country"] != "" {
+				whois += ", " + e["country"]
+			}
+			whois += ")"
+		}
+		parts = append(parts, "whois:"+strings.TrimSpace(whois))
+	}
+	if e["abuse"] != "" {
+		parts = append(parts, "abuse:"+e["abuse"])
+	}
+	if e["blocklist"] != "" {
+		parts = append(parts, "LISTED:"+e["blocklist"])
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+	return "  " + strings.Join(parts, " ")
+}
+
+func (e enrichment) merge(extra map[string]string) map[string]string {
+	if len(e) == 0 {
+		return extra
+	}
+	if extra == nil {
+		extra = make(map[string]string)
+	}
+	for key, value := range e {
+		extra[key] = value
+	}
+	return extra
+}
+
+func ipBetween(ip net.IP, start net.IP, end net.IP) bool {
+	ip16 := ip.To16()
+	return bytes.Compare(ip16, start.To16()) >= 0 && bytes.Compare(ip16, end.To16()) <= 0
+}
+
+// rdapLookup answers from any cached network range before asking the RDAP server.
+func rdapLookup(ip string) enrichment {
+	parsed := net.ParseIP(ip)
+
+	rdapMu.Lock()
+	if info, ok := rdapByIP[ip]; ok {
+		rdapMu.Unlock()
+		return info
+	}
+	for _, entry := range rdapCache {
+		if ipBetween(parsed, entry.start, entry.end) {
+			rdapMu.Unlock()
+			return entry.info
+		}
+	}
+	// threads that hit the same IP at once wait for the lookup already in flight
+	if call, ok := rdapPending[ip]; ok {
+		rdapMu.Unlock()
+		<-call.done
+		return call.info
+	}
+	call := &rdapCall{done: make(chan struct{})}
+	rdapPending[ip] = call
+	rdapMu.Unlock()
+
+	rdapLimiter.wait(context.Background(), 1)
+
+	network, err := fetchRDAP(ip)
+
+	rdapMu.Lock()
+	defer rdapMu.Unlock()
+	defer close(call.done)
+	delete(rdapPending, ip)
+
+	if err != nil {
+		rdapByIP[ip] = nil
+		return nil
+	}
+
+	info := enrichment{
+		"netname": network.Name,
+		"country": network.Country,
+	}
+	for _, entity := range network.Entities {
+		collectRDAPEntity(entity, info)
+	}
+	for key, value := range info {
+		if value == "" {
+			delete(info, key)
+		}
+	}
+
+	start, end := net.ParseIP(network.StartAddress), net.ParseIP(network.EndAddress)
+	if start != nil && end != nil {
+		rdapCache = append(rdapCache, rdapCacheEntry{start: start, end: end, info: info})
+	} else {
+		rdapByIP[ip] = info
+	}
+	call.info = info
+
+	return info
+}
+
+func fetchRDAP(ip string) (*rdapNetwork, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(globalFlagRDAPServer, "/")+"/ip/"+ip, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := rdapClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rdap: unexpected status %s", resp.Status)
+	}
+
+	var network rdapNetwork
+	if err := json.NewDecoder(resp.Body).Decode(&network); err != nil {
+		return nil, err
+	}
+
+	return &network, nil
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func collectRDAPEntity(entity rdapEntity, info enrichment) {
+	fn, email := parseVcard(entity.VcardArray)
+
+	if info["org"] == "" && fn != "" && (hasRole(entity.Roles, "registrant") || hasRole(entity.Roles, "administrative")) {
+		info["org"] = fn
+	}
+	if info["abuse"] == "" && email != "" && hasRole(entity.Roles, "abuse") {
+		info["abuse"] = email
+	}
+
+	for _, child := range entity.Entities {
+		collectRDAPEntity(child, info)
+	}
+}
+
+// parseVcard pulls fn and email out of a jCard: ["vcard", [[name, params, type, value], ...]]
+func parseVcard(raw json.RawMessage) (fn string, email string) {
+	var vcard []json.RawMessage
+	if json.Unmarshal(raw, &vcard) != nil || len(vcard) < 2 {
+		return "", ""
+	}
+
+	var properties [][]json.RawMessage
+	if json.Unmarshal(vcard[1], &properties) != nil {
+		return "", ""
+	}
+
+	for _, property := range properties {
+		if len(property) < 4 {
+			continue
+		}
+
+		var name, value string
+		json.Unmarshal(property[0], &name)
+		if json.Unmarshal(property[3], &value) != nil {
+			continue
+		}
+
+		switch name {
+		case "fn":
+			fn = value
+		case "email":
+			email = value
+		}
+	}
+
+	return fn, email
+}
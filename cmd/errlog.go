@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ayanrajpoot10/bugscanx-go/pkg/queuescanner"
+)
+
+type failureRecord struct {
+	Time       time.Time `json:"time"`
+	Command    string    `json:"command"`
+	Host       string    `json:"host"`
+	Address    string    `json:"address,omitempty"`
+	Phase      string    `json:"phase"`
+	Class      string    `json:"class"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	Tags       []string  `json:"tags,omitempty"`
+}
+
+type phaseError struct {
+	phase string
+	err   error
+}
+
+func (e *phaseError) Error() string {
+	return e.phase + ": " + e.err.Error()
+}
+
+func (e *phaseError) Unwrap() error {
+	return e.err
+}
+
+func withPhase(phase string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &phaseError{phase: phase, err: err}
+}
+
+var (
+	errorLogMu   sync.Mutex
+	errorLogFile *os.File
+)
+
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthErr x509.UnknownAuthorityError
+
+	switch {
+	case err == nil:
+		return "none"
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsNotFound {
+			return "nxdomain"
+		}
+		if dnsErr.IsTimeout {
+			return "dns-timeout"
+		}
+		return "dns"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "reset"
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return "unreachable"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "eof"
+	case errors.As(err, &recordErr), errors.As(err, &alertErr), errors.As(err, &certErr), errors.As(err, &unknownAuthErr):
+		return "tls"
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+
+	return "other"
+}
+
+func isRetryable(err error) bool {
+	switch classifyError(err) {
+	case "timeout", "dns-timeout", "reset", "eof":
+		return true
+	}
+	return false
+}
+
+// retryOnTransient asks the scanner to retry task when err looks temporary.
+func retryOnTransient(ctx *queuescanner.Ctx, task string, err error) {
+	if isRetryable(err) {
+		ctx.Retry(task)
+	}
+}
+
+func logFailure(command string, task string, host string, address string, err error, start time.Time) {
+	phase := "scan"
+	var pe *phaseError
+	if errors.As(err, &pe) {
+		phase = pe.phase
+	}
+
+	// dialing a hostname resolves it first, so attribute lookup failures to dns
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		phase = "dns"
+	}
+
+	logFailureClass(command, task, host, address, phase, classifyError(err), err, start)
+}
+
+func logFailureClass(command string, task string, host string, address string, phase string, class string, err error, start time.Time) {
+	countFailure(command, class)
+	noteFailure(task, phase, class)
+
+	if globalFlagErrorLog == "" && jsonLogOut == nil {
+		return
+	}
+
+	record := failureRecord{
+		Time:       time.Now().UTC(),
+		Command:    command,
+		Host:       host,
+		Address:    address,
+		Phase:      phase,
+		Class:      class,
+		DurationMs: time.Since(start).Milliseconds(),
+		Tags:       globalFlagTags,
+	}
+	var pe *phaseError
+	if errors.As(err, &pe) {
+		record.Error = pe.err.Error()
+	} else if err != nil {
+		record.Error = err.Error()
+	}
+
+	emitFailure(record)
+	if globalFlagErrorLog == "" {
+		return
+	}
+
+	data, jsonErr := json.Marshal(record)
+	if jsonErr != nil {
+		return
+	}
+
+	errorLogMu.Lock()
+	defer errorLogMu.Unlock()
+
+	if errorLogFile == nil {
+		file, err := os.OpenFile(globalFlagErrorLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return
+		}
+		errorLogFile = file
+	}
+
+	errorLogFile.Write(append(data, '\n'))
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ayanrajpoot10/bugscanx-go/pkg/queuescanner"
+)
+
+// existingOutput is the --output of the running command, read by
+// --skip-existing before the scan appends to it.
+var existingOutput string
+
+func setupSkipExisting(cmd *cobra.Command) {
+	if flag := cmd.Flags().Lookup("output"); flag != nil {
+		existingOutput = flag.Value.String()
+	}
+}
+
+// existingHosts collects every host and host:port mentioned in the output
+// file of an earlier run, or returns nil when --skip-existing is off.
+func existingHosts() map[string]bool {
+	if !globalFlagSkipExisting || existingOutput == "" {
+		return nil
+	}
+
+	file, err := os.Open(existingOutput)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		fatal(err)
+	}
+	defer file.Close()
+
+	found := make(map[string]bool)
+	add := func(field string) {
+		field = strings.ToLower(field)
+		found[field] = true
+		if host, _, err := net.SplitHostPort(field); err == nil {
+			found[host] = true
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		var result struct {
+			Host string `json:"host"`
+			Port string `json:"port"`
+		}
+		if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &result) == nil {
+			add(result.Host)
+			if result.Port != "" {
+				add(net.JoinHostPort(result.Host, result.Port))
+			}
+			continue
+		}
+
+		// csv results lead with the host and port, after a header row to skip
+		if strings.HasPrefix(line, queuescanner.CSVHeader) {
+			continue
+		}
+		if fields, err := csv.NewReader(strings.NewReader(line)).Read(); err == nil && len(fields) >= 3 && !strings.ContainsAny(fields[0], " \t") {
+			add(fields[0])
+			if fields[2] != "" {
+				add(net.JoinHostPort(fields[0], fields[2]))
+			}
+			continue
+		}
+
+		if task := textResultTask(strings.Fields(line)); task != "" {
+			add(task)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fatal(err)
+	}
+
+	return found
+}
+
+// textResultTask picks the task out of a text result line, leaving the
+// status, server and other columns alone so they can't pass for hosts.
+func textResultTask(fields []string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	switch activeCommand {
+	case "ping", "sni":
+		// the IP comes first, then the host
+		if len(fields) > 1 {
+			return fields[1]
+		}
+		return ""
+	case "direct":
+		// host:port closes the fixed columns, after a server that may hold
+		// spaces, so look for it rather than count
+		for i := 1; i < len(fields); i++ {
+			host, port, err := net.SplitHostPort(fields[i])
+			if err != nil {
+				continue
+			}
+			if _, err := parsePort(port); err != nil {
+				continue
+			}
+			if strings.HasPrefix(host, "(") {
+				// displayHost's unicode form follows the punycode host
+				return net.JoinHostPort(fields[i-1], port)
+			}
+			return fields[i]
+		}
+		return ""
+	}
+
+	return fields[0]
+}
+
+// skipExisting drops tasks found in the output file by an earlier run.
+func skipExisting(tasks []string, existing map[string]bool) []string {
+	if existing == nil {
+		return tasks
+	}
+
+	var remaining []string
+	for _, task := range tasks {
+		if !existing[task] {
+			remaining = append(remaining, task)
+		}
+	}
+
+	if skipped := len(tasks) - len(remaining); skipped > 0 {
+		fmt.Printf("skipping %d hosts already in %s\n\n", skipped, existingOutput)
+	}
+
+	return remaining
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"sync"
+)
+
+var (
+	failedMu       sync.Mutex
+	lastFailure    = make(map[string]string)
+	succeededHosts = make(map[string]bool)
+)
+
+// failureReason folds an error log phase and class into the coarser reasons
+// written to --failed-output.
+func failureReason(phase string, class string) string {
+	switch class {
+	case "nxdomain", "dns", "dns-timeout", "no-address":
+		return "dns-fail"
+	}
+
+	switch phase {
+	case "dns":
+		return "dns-fail"
+	case "tls":
+		return "handshake-fail"
+	case "dial", "scan":
+		switch class {
+		case "timeout":
+			return "connect-timeout"
+		case "refused":
+			return "connect-refused"
+		case "reset", "unreachable":
+			return class
+		}
+		return "connect-fail"
+	}
+
+	switch class {
+	case "reset", "eof":
+		return "reset"
+	}
+	return class
+}
+
+// noteFailure and noteSuccess are keyed by the whole task, so the ports of
+// one proxy host running at once keep their own outcomes.
+func noteFailure(task string, phase string, class string) {
+	if globalFlagFailedOutput == "" {
+		return
+	}
+
+	failedMu.Lock()
+	lastFailure[task] = failureReason(phase, class)
+	failedMu.Unlock()
+}
+
+func noteSuccess(task string) {
+	if globalFlagFailedOutput == "" {
+		return
+	}
+
+	failedMu.Lock()
+	succeededHosts[task] = true
+	failedMu.Unlock()
+}
+
+// finishTask writes task to --failed-output with its last failure reason
+// unless it produced a result.
+func finishTask(task string) {
+	failedMu.Lock()
+	succeeded, reason := succeededHosts[task], lastFailure[task]
+	delete(succeededHosts, task)
+	delete(lastFailure, task)
+	failedMu.Unlock()
+
+	if succeeded {
+		return
+	}
+	if reason == "" {
+		reason = "no-result"
+	}
+
+	appendToFile(globalFlagFailedOutput, task+" "+reason)
+}
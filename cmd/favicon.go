@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// checkFavicon fetches /favicon.ico from the same address and returns its
+// hash the way Shodan's http.favicon.hash computes it, or "-".
+func checkFavicon(hostCtx context.Context, host string, ipStr string, port string, useTLS bool) string {
+	conn, _, _, err := directConnect(hostCtx, host, ipStr, port, useTLS, nil)
+	if err != nil {
+		return "-"
+	}
+	defer conn.Close()
+
+	httpRequest := fmt.Sprintf("GET /favicon.ico HTTP/1.1\r\nHost: %s\r\nUser-Agent: %s\r\nConnection: close\r\n\r\n", hostHeader(host), globalFlagUserAgent)
+	if _, err := conn.Write([]byte(httpRequest)); err != nil {
+		return "-"
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "GET"})
+	if err != nil {
+		return "-"
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "-"
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, directFlagMaxBody))
+	if err != nil || len(data) == 0 {
+		return "-"
+	}
+	data = decodeBody(resp.Header.Get("Content-Encoding"), data)
+
+	return strconv.Itoa(int(int32(murmur3(faviconBase64(data)))))
+}
+
+// faviconBase64 matches Python's base64.encodebytes, which Shodan hashes:
+// a newline after every 76 characters and at the end.
+func faviconBase64(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+
+	var b strings.Builder
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\n")
+
+	return []byte(b.String())
+}
+
+// murmur3 is MurmurHash3's 32-bit variant with a zero seed.
+func murmur3(data []byte) uint32 {
+	const c1, c2 = 0xcc9e2d51, 0x1b873593
+
+	var h uint32
+	n := len(data)
+	for ; len(data) >= 4; data = data[4:] {
+		k := binary.LittleEndian.Uint32(data)
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	switch len(data) {
+	case 3:
+		k ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(data[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(n)
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+
+	return h
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"strings"
+)
+
+type fingerprintRule struct {
+	name  string
+	match func(headers map[string]string) bool
+}
+
+func headerContains(headers map[string]string, name string, value string) bool {
+	return strings.Contains(strings.ToLower(headers[name]), value)
+}
+
+func headerPresent(headers map[string]string, names ...string) bool {
+	for _, name := range names {
+		if _, ok := headers[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+var fingerprintRules = []fingerprintRule{
+	{"cloudflare", func(h map[string]string) bool {
+		return headerPresent(h, "cf-ray", "cf-cache-status") || headerContains(h, "server", "cloudflare")
+	}},
+	{"cloudfront", func(h map[string]string) bool {
+		return headerPresent(h, "x-amz-cf-id", "x-amz-cf-pop") || headerContains(h, "via", "cloudfront") || headerContains(h, "server", "cloudfront")
+	}},
+	{"awselb", func(h map[string]string) bool {
+		return headerContains(h, "server", "awselb")
+	}},
+	{"amazons3", func(h map[string]string) bool {
+		return headerContains(h, "server", "amazons3")
+	}},
+	{"akamai", func(h map[string]string) bool {
+		return headerPresent(h, "x-akamai-transformed", "akamai-grn") || headerContains(h, "server", "akamai")
+	}},
+	{"fastly", func(h map[string]string) bool {
+		return headerPresent(h, "x-fastly-request-id") || headerContains(h, "x-served-by", "cache-")
+	}},
+	{"google", func(h map[string]string) bool {
+		server := strings.ToLower(h["server"])
+		return server == "gws" || server == "esf" || server == "gse" || strings.Contains(server, "google frontend")
+	}},
+	{"azure", func(h map[string]string) bool {
+		return headerPresent(h, "x-azure-ref", "x-msedge-ref") || headerContains(h, "server", "azure")
+	}},
+	{"varnish", func(h map[string]string) bool {
+		return headerPresent(h, "x-varnish") || headerContains(h, "via", "varnish")
+	}},
+	{"nginx-origin", func(h map[string]string) bool {
+		return headerContains(h, "server", "nginx") && !headerPresent(h, "via", "x-cache")
+	}},
+}
+
+func fingerprintServer(statusCode int, headers map[string]string) string {
+	for _, rule := range fingerprintRules {
+		if rule.match(headers) {
+			return rule.name
+		}
+	}
+
+	server := strings.ToLower(strings.TrimSpace(headers["server"]))
+	if server == "" {
+		if statusCode == 0 {
+			return "unknown"
+		}
+		return "no-server"
+	}
+
+	if idx := strings.IndexAny(server, "/ ("); idx > 0 {
+		server = server[:idx]
+	}
+
+	return server
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harRequest struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Headers     []harHeader `json:"headers"`
+	QueryString []harHeader `json:"queryString"`
+	Cookies     []harHeader `json:"cookies"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+type harResponse struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Headers     []harHeader `json:"headers"`
+	Cookies     []harHeader `json:"cookies"`
+	Content     harContent  `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+type harTimings struct {
+	Blocked int64 `json:"blocked"`
+	DNS     int64 `json:"dns"`
+	Connect int64 `json:"connect"`
+	SSL     int64 `json:"ssl"`
+	Send    int64 `json:"send"`
+	Wait    int64 `json:"wait"`
+	Receive int64 `json:"receive"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            int64       `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress"`
+	Connection      string      `json:"connection,omitempty"`
+}
+
+type harLog struct {
+	Version string `json:"version"`
+	Creator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+var (
+	harMu      sync.Mutex
+	harEntries []harEntry
+)
+
+// parseHARResponse splits a raw response head into the fields HAR expects.
+func parseHARResponse(response string) (httpVersion string, status int, statusText string, headers []harHeader) {
+	headers = []harHeader{}
+
+	lines := strings.Split(response, "\n")
+	if len(lines) > 0 {
+		parts := strings.SplitN(strings.TrimSpace(lines[0]), " ", 3)
+		httpVersion = parts[0]
+		if len(parts) >= 2 {
+			status, _ = strconv.Atoi(parts[1])
+		}
+		if len(parts) == 3 {
+			statusText = parts[2]
+		}
+	}
+
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		headers = append(headers, harHeader{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+	}
+
+	return httpVersion, status, statusText, headers
+}
+
+func addHAREntry(entry harEntry) {
+	harMu.Lock()
+	harEntries = append(harEntries, entry)
+	harMu.Unlock()
+}
+
+func writeHAR(filename string) error {
+	harMu.Lock()
+	defer harMu.Unlock()
+
+	var log harLog
+	log.Version = "1.2"
+	log.Creator.Name = "bugscanx-go"
+	log.Creator.Version = "1.0"
+	log.Entries = harEntries
+	if log.Entries == nil {
+		log.Entries = []harEntry{}
+	}
+
+	data, err := json.MarshalIndent(map[string]harLog{"log": log}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, data, 0644)
+}
+
+func harTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
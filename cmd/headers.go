@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// requestHeader is one -H "Name: value".
+type requestHeader struct {
+	name  string
+	value string
+}
+
+func parseRequestHeaders(flags []string) ([]requestHeader, error) {
+	headers := make([]requestHeader, 0, len(flags))
+	for _, flag := range flags {
+		name, value, found := strings.Cut(flag, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header: %q (expected \"Name: value\")", flag)
+		}
+		headers = append(headers, requestHeader{name: name, value: strings.TrimSpace(value)})
+	}
+	return headers, nil
+}
+
+// hostHeader brackets IPv6 literals as a Host header needs them.
+func hostHeader(host string) string {
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// applyRequestHeaders sets headers on h, replacing defaults of the same name.
+// A Host header is returned rather than set since net/http takes it from
+// the request itself.
+func applyRequestHeaders(h http.Header, headers []requestHeader) (host string) {
+	replaced := make(map[string]bool)
+	for _, header := range headers {
+		key := http.CanonicalHeaderKey(header.name)
+		if key == "Host" {
+			host = header.value
+			continue
+		}
+		if !replaced[key] {
+			h.Del(key)
+			replaced[key] = true
+		}
+		h.Add(key, header.value)
+	}
+	return host
+}
+
+// insertPayloadHeaders adds headers at the end of the header block of a
+// payload, before its first blank line written as [crlf][crlf] or \r\n\r\n.
+// Payloads without one aren't HTTP and are left alone.
+func insertPayloadHeaders(payload string, headers []requestHeader) string {
+	if len(headers) == 0 {
+		return payload
+	}
+
+	for _, crlf := range []string{"[crlf]", "\r\n"} {
+		end := strings.Index(payload, crlf+crlf)
+		if end < 0 {
+			continue
+		}
+
+		var lines strings.Builder
+		for _, header := range headers {
+			lines.WriteString(crlf + header.name + ": " + header.value)
+		}
+		return payload[:end] + lines.String() + payload[end:]
+	}
+
+	return payload
+}
+
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// saveResponseHeaders writes response, the status line and headers, to its
+// own file in dir named after the host, address and port, since a host
+// can answer on several addresses.
+func saveResponseHeaders(dir string, url string, host string, ipStr string, port string, response string) error {
+	name := unsafeFilename.ReplaceAllString(host+"_"+ipStr+"_"+port, "_") + ".txt"
+
+	content := fmt.Sprintf("# %s (%s)\n%s", url, ipStr, strings.ReplaceAll(response, "\r\n", "\n"))
+	return os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import "testing"
+
+func TestPunycodeEncode(t *testing.T) {
+	// from RFC 3492 section 7.1, with the mixed-case annotations dropped
+	tests := []struct {
+		label string
+		want  string
+	}{
+		{"他们为什么不说中文", "ihqwcrb4cv8a8dqg056pqjye"},
+		{"他們爲什麽不說中文", "ihqwctvzc91f659drss3x8bo0yb"},
+		{"למההםפשוטלאמדבריםעברית", "4dbcagdahymbxekheh6e0a7fei0b"},
+		{"почемужеонинеговорятпорусски", "b1abfaaepdrnnbgefbadotcwatmq2g4l"},
+		{"3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+		{"安室奈美恵-with-SUPER-MONKEYS", "-with-SUPER-MONKEYS-pc58ag80a8qai00g7n9n"},
+		{"Hello-Another-Way-それぞれの場所", "Hello-Another-Way--fc4qua05auwb3674vfr0b"},
+		{"bücher", "bcher-kva"},
+		{"münchen", "mnchen-3ya"},
+	}
+
+	for _, test := range tests {
+		if got := punycodeEncode(test.label); got != test.want {
+			t.Errorf("punycodeEncode(%q) = %q, want %q", test.label, got, test.want)
+		}
+	}
+}
+
+func TestToASCIIHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"example.com", "example.com"},
+		{"Bücher.example", "xn--bcher-kva.example"},
+		{"münchen.bücher.de", "xn--mnchen-3ya.xn--bcher-kva.de"},
+	}
+
+	for _, test := range tests {
+		if got := toASCIIHost(test.host); got != test.want {
+			t.Errorf("toASCIIHost(%q) = %q, want %q", test.host, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"os"
+)
+
+// jsonLogOut is the real stdout when --log-json is set; everything else
+// printed by the commands goes to stderr so stdout stays machine readable.
+var jsonLogOut *os.File
+
+func setupJSONLog() {
+	if !globalFlagLogJSON {
+		return
+	}
+
+	jsonLogOut = os.Stdout
+	os.Stdout = os.Stderr
+}
+
+func emitFailure(record failureRecord) {
+	if jsonLogOut == nil {
+		return
+	}
+
+	metricsMu.Lock()
+	qs := metricsScanner
+	metricsMu.Unlock()
+	if qs == nil {
+		return
+	}
+
+	qs.Event("error", map[string]any{
+		"command":     record.Command,
+		"host":        record.Host,
+		"address":     record.Address,
+		"phase":       record.Phase,
+		"class":       record.Class,
+		"error":       record.Error,
+		"duration_ms": record.DurationMs,
+	})
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ayanrajpoot10/bugscanx-go/pkg/queuescanner"
+)
+
+// latencyBuckets are the histogram upper bounds in seconds.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type latencyHistogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+var (
+	metricsMu      sync.Mutex
+	metricsScanner *queuescanner.QueueScanner
+	errorCounts    = make(map[[2]string]uint64)
+	latencies      = make(map[string]*latencyHistogram)
+)
+
+func startMetrics() error {
+	if globalFlagMetrics == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", globalFlagMetrics)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w)
+	})
+	go http.Serve(listener, mux)
+
+	return nil
+}
+
+func countFailure(command string, class string) {
+	if globalFlagMetrics == "" {
+		return
+	}
+
+	metricsMu.Lock()
+	errorCounts[[2]string{command, class}]++
+	metricsMu.Unlock()
+}
+
+func observeLatency(command string, latency time.Duration) {
+	if globalFlagMetrics == "" {
+		return
+	}
+
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	h := latencies[command]
+	if h == nil {
+		h = &latencyHistogram{counts: make([]uint64, len(latencyBuckets))}
+		latencies[command] = h
+	}
+
+	seconds := latency.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func writeMetrics(w io.Writer) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	if metricsScanner != nil {
+		stats := metricsScanner.Stats()
+
+		depth := stats.Queued - stats.Completed - stats.Active
+		if stats.Total >= 0 {
+			depth = stats.Total - stats.Completed - stats.Active
+		}
+
+		writeMetric(w, "bugscanx_queue_depth", "gauge", "Hosts waiting to be scanned.", depth)
+		writeMetric(w, "bugscanx_workers_busy", "gauge", "Workers currently running a probe.", stats.Active)
+		writeMetric(w, "bugscanx_hosts_completed_total", "counter", "Hosts scanned.", stats.Completed)
+		writeMetric(w, "bugscanx_success_total", "counter", "Successful results.", stats.Success)
+		writeMetric(w, "bugscanx_duplicates_total", "counter", "Results dropped by --unique-ip.", stats.Duplicates)
+		writeMetric(w, "bugscanx_retries_total", "counter", "Retried attempts.", stats.Retries)
+	}
+
+	fmt.Fprintln(w, "# HELP bugscanx_errors_total Failed probes by command and failure class.")
+	fmt.Fprintln(w, "# TYPE bugscanx_errors_total counter")
+	keys := make([][2]string, 0, len(errorCounts))
+	for key := range errorCounts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0]+"\n"+keys[i][1] < keys[j][0]+"\n"+keys[j][1]
+	})
+	for _, key := range keys {
+		fmt.Fprintf(w, "bugscanx_errors_total{command=%q,class=%q} %d\n", key[0], key[1], errorCounts[key])
+	}
+
+	fmt.Fprintln(w, "# HELP bugscanx_success_latency_seconds Latency of successful results.")
+	fmt.Fprintln(w, "# TYPE bugscanx_success_latency_seconds histogram")
+	commands := make([]string, 0, len(latencies))
+	for command := range latencies {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	for _, command := range commands {
+		h := latencies[command]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "bugscanx_success_latency_seconds_bucket{command=%q,le=\"%g\"} %d\n", command, bound, h.counts[i])
+		}
+		fmt.Fprintf(w, "bugscanx_success_latency_seconds_bucket{command=%q,le=\"+Inf\"} %d\n", command, h.count)
+		fmt.Fprintf(w, "bugscanx_success_latency_seconds_sum{command=%q} %g\n", command, h.sum)
+		fmt.Fprintf(w, "bugscanx_success_latency_seconds_count{command=%q} %d\n", command, h.count)
+	}
+}
+
+func writeMetric(w io.Writer, name string, kind string, help string, value int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, value)
+}
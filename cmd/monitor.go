@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ayanrajpoot10/bugscanx-go/pkg/resultstore"
+)
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor",
+	Short: "Monitor host availability over time and alert when working hosts go dark.",
+	Run:   runMonitor,
+}
+
+var (
+	monitorFlagFilename string
+	monitorFlagPort     string
+	monitorFlagMode     string
+	monitorFlagInterval time.Duration
+	monitorFlagCount    int
+	monitorFlagTimeout  int
+	monitorFlagOutput   string
+	monitorFlagCertWarn int
+)
+
+type monitorState struct {
+	checks   int
+	ups      int
+	up       bool
+	known    bool
+	lastSeen time.Time
+
+	issuer string
+	warned string
+}
+
+func init() {
+	rootCmd.AddCommand(monitorCmd)
+
+	monitorCmd.Flags().StringVarP(&monitorFlagFilename, "filename", "f", "", "domain list filename")
+	monitorCmd.Flags().StringVarP(&monitorFlagPort, "port", "p", "443", "port to probe")
+	monitorCmd.Flags().StringVar(&monitorFlagMode, "mode", "tcp", "probe mode - tcp (connect), tls (handshake with the host as SNI) or cert (tls plus certificate expiry and issuer tracking)")
+	monitorCmd.Flags().DurationVar(&monitorFlagInterval, "interval", time.Minute, "delay between check rounds")
+	monitorCmd.Flags().IntVar(&monitorFlagCount, "count", 0, "number of check rounds, 0 to run until interrupted")
+	monitorCmd.Flags().IntVar(&monitorFlagTimeout, "timeout", 3, "probe timeout in seconds")
+	monitorCmd.Flags().IntVar(&monitorFlagCertWarn, "cert-warn", 14, "in cert mode, alert when a certificate expires within this many days")
+	monitorCmd.Flags().StringVarP(&monitorFlagOutput, "output", "o", "", "append alerts to this file")
+}
+
+func monitorProbe(host string) (string, time.Duration, bool, *x509.Certificate) {
+	timeout := time.Duration(monitorFlagTimeout) * time.Second
+
+	hostCtx, hostCancel := hostContext(context.Background())
+	defer hostCancel()
+
+	dialCtx, dialCancel := context.WithTimeout(hostCtx, timeout)
+	defer dialCancel()
+
+	start := time.Now()
+	conn, err := dialContext(dialCtx, "tcp", net.JoinHostPort(host, monitorFlagPort))
+	if err != nil {
+		return "", 0, false, nil
+	}
+	defer conn.Close()
+
+	ip, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+
+	var cert *x509.Certificate
+	if monitorFlagMode == "tls" || monitorFlagMode == "cert" {
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true,
+		})
+
+		handshakeCtx, cancel := context.WithTimeout(hostCtx, timeout)
+		defer cancel()
+
+		if err := tlsConn.HandshakeContext(handshakeCtx); err != nil {
+			return ip, 0, false, nil
+		}
+
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			cert = certs[0]
+		}
+	}
+
+	return ip, time.Since(start), true, cert
+}
+
+func certExtra(cert *x509.Certificate) map[string]string {
+	sum := sha256.Sum256(cert.Raw)
+
+	issuer := cert.Issuer.CommonName
+	if issuer == "" && len(cert.Issuer.Organization) > 0 {
+		issuer = cert.Issuer.Organization[0]
+	}
+
+	return map[string]string{
+		"issuer":      issuer,
+		"fingerprint": hex.EncodeToString(sum[:]),
+		"expires":     cert.NotAfter.UTC().Format(time.RFC3339),
+	}
+}
+
+func certDaysLeft(expires string) (int, bool) {
+	at, err := time.Parse(time.RFC3339, expires)
+	if err != nil {
+		return 0, false
+	}
+	return int(time.Until(at).Hours() / 24), true
+}
+
+func loadMonitorStates(store *resultstore.Store) (map[string]*monitorState, error) {
+	states := make(map[string]*monitorState)
+
+	err := store.Each(func(record resultstore.Record) error {
+		if record.Command != "monitor" || record.Port != monitorFlagPort || record.Extra["mode"] != monitorFlagMode {
+			return nil
+		}
+
+		state, ok := states[record.Host]
+		if !ok {
+			state = &monitorState{}
+			states[record.Host] = state
+		}
+		state.record(record.Success, record.Time)
+		if issuer := record.Extra["issuer"]; issuer != "" {
+			state.issuer = issuer
+		}
+
+		return nil
+	})
+
+	return states, err
+}
+
+func (state *monitorState) record(up bool, at time.Time) {
+	state.checks++
+	state.up = up
+	state.known = true
+	if up {
+		state.ups++
+		if at.After(state.lastSeen) {
+			state.lastSeen = at
+		}
+	}
+}
+
+func (state *monitorState) uptime() float64 {
+	if state.checks == 0 {
+		return 0
+	}
+	return float64(state.ups) / float64(state.checks) * 100
+}
+
+func (state *monitorState) lastSeenString() string {
+	if state.lastSeen.IsZero() {
+		return "never"
+	}
+	return state.lastSeen.Local().Format("2006-01-02 15:04:05")
+}
+
+func runMonitor(cmd *cobra.Command, args []string) {
+	hosts, err := ReadFile(monitorFlagFilename)
+	if err != nil {
+		fatal(err)
+	}
+
+	ports, err := parsePorts(monitorFlagPort)
+	if err != nil {
+		fatal(err)
+	}
+	if len(ports) != 1 {
+		fatal(fmt.Errorf("monitor probes a single port, got %s", monitorFlagPort))
+	}
+	monitorFlagPort = ports[0]
+
+	switch monitorFlagMode {
+	case "tcp", "tls", "cert":
+	default:
+		fatal(fmt.Errorf("invalid mode: %s", monitorFlagMode))
+	}
+
+	store, err := openStore()
+	if err != nil {
+		fatal(err)
+	}
+
+	states, err := loadMonitorStates(store)
+	if err != nil {
+		fatal(err)
+	}
+
+	for _, host := range hosts {
+		if _, ok := states[host]; !ok {
+			states[host] = &monitorState{}
+		}
+	}
+
+	run := resultstore.NewRunID()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	for round := 1; monitorFlagCount == 0 || round <= monitorFlagCount; round++ {
+		records := make([]resultstore.Record, len(hosts))
+
+		sem := make(chan struct{}, globalFlagThreads)
+		var wg sync.WaitGroup
+
+		for i, host := range hosts {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, host string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				ip, latency, up, cert := monitorProbe(host)
+				records[i] = resultstore.Record{
+					Time:      time.Now().UTC(),
+					Run:       run,
+					Command:   "monitor",
+					Host:      host,
+					IP:        ip,
+					Port:      monitorFlagPort,
+					Success:   up,
+					LatencyMs: latency.Milliseconds(),
+					Extra:     map[string]string{"mode": monitorFlagMode},
+					Tags:      globalFlagTags,
+				}
+				if cert != nil && monitorFlagMode == "cert" {
+					for key, value := range certExtra(cert) {
+						records[i].Extra[key] = value
+					}
+				}
+			}(i, host)
+		}
+		wg.Wait()
+
+		if err := store.Append(records...); err != nil {
+			fatal(err)
+		}
+
+		fmt.Printf("%s  round %d\n", time.Now().Format("2006-01-02 15:04:05"), round)
+		if monitorFlagMode == "cert" {
+			fmt.Printf("%-32s %-5s %8s  %-19s  %-8s  %s\n", "Host", "State", "Uptime", "Last Seen", "Expires", "Issuer")
+		} else {
+			fmt.Printf("%-32s %-5s %8s  %s\n", "Host", "State", "Uptime", "Last Seen")
+		}
+
+		var alerts []string
+		for i, host := range hosts {
+			state := states[host]
+			wasUp := state.known && state.up
+			previouslySeen := state.lastSeenString()
+
+			state.record(records[i].Success, records[i].Time)
+
+			status := "down"
+			if records[i].Success {
+				status = "up"
+			}
+
+			address := net.JoinHostPort(host, monitorFlagPort)
+			now := time.Now().Format("2006-01-02 15:04:05")
+
+			if monitorFlagMode == "cert" {
+				extra := records[i].Extra
+				expires, issuer := "-", extra["issuer"]
+				daysLeft, hasCert := certDaysLeft(extra["expires"])
+				if hasCert {
+					expires = fmt.Sprintf("%dd", daysLeft)
+				}
+
+				fmt.Printf("%-32s %-5s %7.1f%%  %-19s  %-8s  %s\n", displayHost(host)+":"+monitorFlagPort, status, state.uptime(), state.lastSeenString(), expires, issuer)
+
+				if hasCert {
+					if state.issuer != "" && issuer != state.issuer {
+						alerts = append(alerts, fmt.Sprintf("%s  ALERT %s certificate issuer changed from %s to %s", now, address, state.issuer, issuer))
+					}
+
+					// warn once per certificate rather than every round
+					if daysLeft <= monitorFlagCertWarn && state.warned != extra["fingerprint"] {
+						alerts = append(alerts, fmt.Sprintf("%s  ALERT %s certificate expires in %d days (%s)", now, address, daysLeft, extra["expires"]))
+						state.warned = extra["fingerprint"]
+					}
+
+					state.issuer = issuer
+				}
+			} else {
+				fmt.Printf("%-32s %-5s %7.1f%%  %s\n", displayHost(host)+":"+monitorFlagPort, status, state.uptime(), state.lastSeenString())
+			}
+
+			if wasUp && !records[i].Success {
+				alerts = append(alerts, fmt.Sprintf("%s  ALERT %s went dark (last seen %s)", now, address, previouslySeen))
+			}
+		}
+
+		for _, alert := range alerts {
+			fmt.Println("\a" + alert)
+		}
+
+		if len(alerts) > 0 && monitorFlagOutput != "" {
+			if err := appendToFile(monitorFlagOutput, alerts...); err != nil {
+				fatal(err)
+			}
+		}
+		fmt.Println()
+
+		if monitorFlagCount != 0 && round == monitorFlagCount {
+			break
+		}
+
+		select {
+		case <-sigChan:
+			return
+		case <-time.After(monitorFlagInterval):
+		}
+	}
+}
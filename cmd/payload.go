@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+)
+
+var payloadFuncRegex = regexp.MustCompile(`\[(urlencode|b64|hexlify):([^\[\]]*)\]`)
+
+// expandPayloadFuncs applies [urlencode:...], [b64:...] and [hexlify:...]
+// after the plain placeholders are replaced, innermost first so they nest.
+func expandPayloadFuncs(payload string) string {
+	for payloadFuncRegex.MatchString(payload) {
+		payload = payloadFuncRegex.ReplaceAllStringFunc(payload, func(match string) string {
+			parts := payloadFuncRegex.FindStringSubmatch(match)
+			switch parts[1] {
+			case "urlencode":
+				return url.QueryEscape(parts[2])
+			case "b64":
+				return base64.StdEncoding.EncodeToString([]byte(parts[2]))
+			default:
+				return hex.EncodeToString([]byte(parts[2]))
+			}
+		})
+	}
+	return payload
+}
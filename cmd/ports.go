@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//go:embed data/top-ports.json
+var embeddedTopPorts []byte
+
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bugscanx-go"), nil
+}
+
+func loadTopPorts() (map[string]string, error) {
+	presets := make(map[string]string)
+	if err := json.Unmarshal(loadDataset("top-ports.json"), &presets); err != nil {
+		return nil, err
+	}
+
+	dir, err := configDir()
+	if err != nil {
+		return presets, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "top-ports.json"))
+	if err != nil {
+		return presets, nil
+	}
+
+	overrides := make(map[string]string)
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("invalid top-ports config: %w", err)
+	}
+	for name, ports := range overrides {
+		presets[name] = ports
+	}
+
+	return presets, nil
+}
+
+func resolvePorts(portSpec string, portChanged bool, topPorts string, excludePorts string) ([]string, error) {
+	var specs []string
+	if topPorts == "" || portChanged {
+		specs = append(specs, portSpec)
+	}
+
+	if topPorts != "" {
+		presets, err := loadTopPorts()
+		if err != nil {
+			return nil, err
+		}
+
+		preset, ok := presets[topPorts]
+		if !ok {
+			names := make([]string, 0, len(presets))
+			for name := range presets {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown top-ports preset: %s (available: %s)", topPorts, strings.Join(names, ", "))
+		}
+		specs = append(specs, preset)
+	}
+
+	ports, err := parsePorts(strings.Join(specs, ","))
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[string]bool)
+	if excludePorts != "" {
+		excludeList, err := parsePorts(excludePorts)
+		if err != nil {
+			return nil, err
+		}
+		for _, port := range excludeList {
+			excluded[port] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	var result []string
+	for _, port := range ports {
+		if excluded[port] || seen[port] {
+			continue
+		}
+		seen[port] = true
+		result = append(result, port)
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no ports left to scan")
+	}
+
+	return result, nil
+}
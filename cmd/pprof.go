@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+)
+
+func startPprof() error {
+	if globalFlagPprof == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", globalFlagPprof)
+	if err != nil {
+		return err
+	}
+
+	go http.Serve(listener, nil)
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// checkQUIC sends a QUIC long-header packet with a reserved version to the
+// UDP side of port. Any QUIC server has to answer it with a version
+// negotiation packet (RFC 9000 section 6), which lists the versions it speaks
+// without needing a handshake. It returns those versions, or "no".
+func checkQUIC(hostCtx context.Context, ipStr string, port string) string {
+	network := "udp4"
+	if ip := net.ParseIP(ipStr); ip != nil && ip.To4() == nil {
+		network = "udp6"
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(hostCtx, network, net.JoinHostPort(ipStr, port))
+	if err != nil {
+		return "-"
+	}
+	defer conn.Close()
+	setHostDeadline(hostCtx, conn, time.Duration(directFlagTimeoutConnect)*time.Second)
+
+	dcid := make([]byte, 8)
+	scid := make([]byte, 8)
+	rand.Read(dcid)
+	rand.Read(scid)
+
+	// servers only answer datagrams at least as large as a client Initial
+	packet := make([]byte, 1200)
+	packet[0] = 0xc0
+	binary.BigEndian.PutUint32(packet[1:], 0x1a2a3a4a)
+	packet[5] = byte(len(dcid))
+	copy(packet[6:], dcid)
+	packet[14] = byte(len(scid))
+	copy(packet[15:], scid)
+
+	if _, err := conn.Write(packet); err != nil {
+		return "-"
+	}
+
+	buffer := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return "no"
+		}
+		if versions, ok := parseVersionNegotiation(buffer[:n], scid); ok {
+			return versions
+		}
+	}
+}
+
+func parseVersionNegotiation(packet []byte, scid []byte) (string, bool) {
+	if len(packet) < 7 || packet[0]&0x80 == 0 || binary.BigEndian.Uint32(packet[1:]) != 0 {
+		return "", false
+	}
+
+	// the server echoes our source connection id back as the destination
+	rest := packet[5:]
+	dcidLen := int(rest[0])
+	if len(rest) < 1+dcidLen+1 || !bytes.Equal(rest[1:1+dcidLen], scid) {
+		return "", false
+	}
+	rest = rest[1+dcidLen:]
+	scidLen := int(rest[0])
+	if len(rest) < 1+scidLen {
+		return "", false
+	}
+	rest = rest[1+scidLen:]
+
+	var versions []string
+	for ; len(rest) >= 4; rest = rest[4:] {
+		if name := quicVersionName(binary.BigEndian.Uint32(rest)); name != "" {
+			versions = append(versions, name)
+		}
+	}
+	if len(versions) == 0 {
+		return "", false
+	}
+
+	return strings.Join(versions, ","), true
+}
+
+func quicVersionName(version uint32) string {
+	switch {
+	case version&0x0f0f0f0f == 0x0a0a0a0a:
+		// reserved versions servers advertise to keep negotiation exercised
+		return ""
+	case version == 0x00000001:
+		return "v1"
+	case version == 0x6b3343cf:
+		return "v2"
+	case version>>8 == 0xff0000:
+		return fmt.Sprintf("draft-%d", version&0xff)
+	default:
+		return fmt.Sprintf("0x%08x", version)
+	}
+}
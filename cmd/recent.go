@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/ayanrajpoot10/bugscanx-go/pkg/queuescanner"
+	"github.com/ayanrajpoot10/bugscanx-go/pkg/resultstore"
+)
+
+var (
+	activeCommand string
+	scanParams    string
+	probeStore    *resultstore.Store
+)
+
+// flags that pick inputs or outputs rather than changing what a probe does
+var unparameterizedFlags = map[string]bool{
+	"filename": true,
+	"output":   true,
+	"har":      true,
+	"cidr":     true,
+	"proxy":    true,
+	"help":     true,
+}
+
+func paramSignature(cmd *cobra.Command) string {
+	var params []string
+	cmd.NonInheritedFlags().VisitAll(func(flag *pflag.Flag) {
+		if !unparameterizedFlags[flag.Name] {
+			params = append(params, flag.Name+"="+flag.Value.String())
+		}
+	})
+	params = append(params, "via="+strings.Join(globalFlagVia, ","))
+	sort.Strings(params)
+
+	sum := sha256.Sum256([]byte(cmd.Name() + "\n" + strings.Join(params, "\n")))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+func setupSkipRecent(cmd *cobra.Command) error {
+	activeCommand = cmd.Name()
+	scanParams = paramSignature(cmd)
+
+	if globalFlagSkipRecent <= 0 {
+		return nil
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	path := store.Path()
+	probeStore, err = resultstore.Open(filepath.Join(filepath.Dir(path), "probes.jsonl"))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// recentlyProbed returns the tasks probed with the same parameters within
+// --skip-recent, or nil when it is off.
+func recentlyProbed() map[string]bool {
+	if probeStore == nil || globalFlagForce {
+		return nil
+	}
+
+	since := time.Now().Add(-globalFlagSkipRecent)
+	recent := make(map[string]bool)
+
+	err := probeStore.Each(func(record resultstore.Record) error {
+		if record.Command == activeCommand && record.Extra["params"] == scanParams && record.Time.After(since) {
+			recent[record.Host] = true
+		}
+		return nil
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	return recent
+}
+
+// skipRecent drops tasks probed with the same parameters within --skip-recent.
+func skipRecent(tasks []string) []string {
+	recent := recentlyProbed()
+	if recent == nil {
+		return tasks
+	}
+
+	var remaining []string
+	for _, task := range tasks {
+		if !recent[task] {
+			remaining = append(remaining, task)
+		}
+	}
+
+	if skipped := len(tasks) - len(remaining); skipped > 0 {
+		fmt.Printf("skipping %d hosts probed in the last %s (use --force to rescan)\n\n", skipped, globalFlagSkipRecent)
+	}
+
+	return remaining
+}
+
+// knownGood returns the tasks that produced a result for this command in an
+// earlier recorded run.
+func knownGood(tasks []string) []string {
+	store, err := openStore()
+	if err != nil {
+		fatal(err)
+	}
+
+	good := make(map[string]bool)
+	err = store.Each(func(record resultstore.Record) error {
+		if record.Command == activeCommand && record.Success {
+			good[record.Host] = true
+			if record.Port != "" {
+				good[net.JoinHostPort(record.Host, record.Port)] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	var known []string
+	for _, task := range tasks {
+		if good[task] {
+			known = append(known, task)
+		}
+	}
+
+	return known
+}
+
+// trackProbes records every finished task so later runs can skip it.
+func trackProbes(scanFunc func(c *queuescanner.Ctx, host string)) func(c *queuescanner.Ctx, host string) {
+	if probeStore == nil {
+		return scanFunc
+	}
+
+	return func(c *queuescanner.Ctx, host string) {
+		scanFunc(c, host)
+		probeStore.Append(resultstore.Record{
+			Time:    time.Now().UTC(),
+			Command: activeCommand,
+			Host:    host,
+			Extra:   map[string]string{"params": scanParams},
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ayanrajpoot10/bugscanx-go/pkg/resultstore"
+)
+
+var resultsCmd = &cobra.Command{
+	Use:   "results",
+	Short: "List stored results matching a filter expression.",
+	Example: `  bugscanx-go results --where 'status==200 && server=~"cloudflare" && latency<300ms'
+  bugscanx-go results --where 'command==monitor && success==false'`,
+	Run: runResults,
+}
+
+var (
+	resultsFlagWhere string
+	resultsFlagLimit int
+)
+
+func init() {
+	rootCmd.AddCommand(resultsCmd)
+
+	resultsCmd.Flags().StringVarP(&resultsFlagWhere, "where", "w", "", "filter expression over host, ip, port, command, run, success, latency, tag and recorded fields such as status, server, fingerprint or cdn")
+	resultsCmd.Flags().IntVar(&resultsFlagLimit, "limit", 0, "show at most this many of the most recent matches (0 for all)")
+}
+
+func runResults(cmd *cobra.Command, args []string) {
+	filter, err := resultstore.ParseFilter(resultsFlagWhere)
+	if err != nil {
+		fatal(err)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		fatal(err)
+	}
+
+	var matches []resultstore.Record
+	err = store.Each(func(record resultstore.Record) error {
+		if filter.Match(record) {
+			matches = append(matches, record)
+		}
+		return nil
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	if resultsFlagLimit > 0 && len(matches) > resultsFlagLimit {
+		matches = matches[len(matches)-resultsFlagLimit:]
+	}
+
+	fmt.Printf("%-19s  %-8s  %-15s  %-5s  %-6s  %-8s  %-14s  %s\n", "Time", "Command", "IP", "Port", "Status", "Latency", "CDN", "Host")
+	fmt.Printf("%-19s  %-8s  %-15s  %-5s  %-6s  %-8s  %-14s  %s\n", "----", "-------", "--", "----", "------", "-------", "---", "----")
+
+	for _, record := range matches {
+		status := record.Extra["status"]
+		if status == "" {
+			status = "-"
+			if !record.Success {
+				status = "down"
+			}
+		}
+
+		line := fmt.Sprintf("%-19s  %-8s  %-15s  %-5s  %-6s  %-8s  %-14s  %s", record.Time.Local().Format("2006-01-02 15:04:05"), record.Command, orDash(record.IP), orDash(record.Port), status, fmt.Sprintf("%dms", record.LatencyMs), orDash(record.Extra["cdn"]), record.Host)
+		if len(record.Tags) > 0 {
+			line += "  [" + strings.Join(record.Tags, ",") + "]"
+		}
+		fmt.Println(line)
+	}
+
+	fmt.Printf("\n%d results\n", len(matches))
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
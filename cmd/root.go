@@ -1,17 +1,92 @@
 package cmd
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/spf13/cobra"
 )
 
 var rootCmd = &cobra.Command{
 	Use:  "bugscanx-go",
 	Long: "A bugscanner-go fork.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if globalFlagTUI && globalFlagLogJSON {
+			return fmt.Errorf("--tui and --log-json can't be combined")
+		}
+		if err := setupShard(); err != nil {
+			return err
+		}
+		if err := validateSortKey(); err != nil {
+			return err
+		}
+		if err := openRecordStore(); err != nil {
+			return err
+		}
+		if err := setupBandwidthLimit(); err != nil {
+			return err
+		}
+		if err := setupViaChain(); err != nil {
+			return err
+		}
+		if err := validateEnrich(); err != nil {
+			return err
+		}
+		setupSkipExisting(cmd)
+		if err := setupSkipRecent(cmd); err != nil {
+			return err
+		}
+		setupJSONLog()
+		if err := startPprof(); err != nil {
+			return err
+		}
+		return startMetrics()
+	},
 }
 
 var (
-	globalFlagThreads      int
-	globalFlagStatInterval float64
+	globalFlagThreads       int
+	globalFlagStatInterval  float64
+	globalFlagStore         string
+	globalFlagUniqueIP      bool
+	globalFlagSort          string
+	globalFlagNoColor       bool
+	globalFlagErrorLog      string
+	globalFlagPprof         string
+	globalFlagHostTimeout   time.Duration
+	globalFlagUserAgent     string
+	globalFlagDNSCacheTTL   time.Duration
+	globalFlagTaskTimeout   time.Duration
+	globalFlagOffline       bool
+	globalFlagRecord        bool
+	globalFlagTags          []string
+	globalFlagMaxBandwidth  string
+	globalFlagVia           []string
+	globalFlagEnrich        []string
+	globalFlagRDAPServer    string
+	globalFlagRDAPRate      float64
+	globalFlagBlocklists    []string
+	globalFlagSkipRecent    time.Duration
+	globalFlagForce         bool
+	globalFlagSkipExisting  bool
+	globalFlagResume        bool
+	globalFlagRate          float64
+	globalFlagSink          string
+	globalFlagMetrics       string
+	globalFlagFailedOutput  string
+	globalFlagKnownFirst    bool
+	globalFlagInterleave    bool
+	globalFlagShard         string
+	globalFlagMaxRuntime    time.Duration
+	globalFlagMaxSuccess    int
+	globalFlagLogJSON       bool
+	globalFlagStatMultiline bool
+	globalFlagTUI           bool
+	globalFlagLogTail       int
+	globalFlagPace          time.Duration
+	globalFlagJitter        time.Duration
+	globalFlagRetries       int
+	globalFlagRetryBackoff  time.Duration
 )
 
 func Execute() {
@@ -23,4 +98,44 @@ func init() {
 	rootCmd.SetHelpCommand(&cobra.Command{Hidden: true})
 	rootCmd.PersistentFlags().IntVarP(&globalFlagThreads, "threads", "t", 64, "total threads to use")
 	rootCmd.PersistentFlags().Float64Var(&globalFlagStatInterval, "stat-interval", 1.0, "stat interval in seconds")
+	rootCmd.PersistentFlags().BoolVar(&globalFlagStatMultiline, "stat-multiline", false, "show the live status on two lines with rate, success rate and busy workers")
+	rootCmd.PersistentFlags().IntVar(&globalFlagLogTail, "log-tail", 0, "repeat the last N log messages when the scan ends, so ones that scrolled past under the status line aren't lost")
+	rootCmd.PersistentFlags().BoolVar(&globalFlagTUI, "tui", false, "full-screen view with a progress gauge and the latest results, press / to filter them")
+	rootCmd.PersistentFlags().BoolVar(&globalFlagUniqueIP, "unique-ip", false, "only report the first successful result per IP, counting the rest as duplicates")
+	rootCmd.PersistentFlags().StringVar(&globalFlagSort, "sort", "", "sort the output file when the scan completes - ip, host, latency or status")
+	rootCmd.PersistentFlags().BoolVar(&globalFlagNoColor, "no-color", false, "disable colored results (also disabled when NO_COLOR is set or output is not a terminal)")
+	rootCmd.PersistentFlags().StringVar(&globalFlagErrorLog, "error-log", "", "append per-host failures (host, phase, error class, duration) to this file as NDJSON")
+	rootCmd.PersistentFlags().StringVar(&globalFlagPprof, "pprof", "", "serve net/http/pprof debug endpoints on this address e.g. :6060")
+	rootCmd.PersistentFlags().StringVar(&globalFlagUserAgent, "user-agent", "bugscanx-go/1.0", "User-Agent sent by direct, and filled in for [ua] in payloads")
+	rootCmd.PersistentFlags().DurationVar(&globalFlagHostTimeout, "host-timeout", 0, "total time a single host may take across dns, dial, handshake and read e.g. 20s (0 keeps the per-phase defaults)")
+	rootCmd.PersistentFlags().DurationVar(&globalFlagTaskTimeout, "task-timeout", 0, "hard limit per host, a probe still running after this is abandoned and its thread moves on e.g. 60s (0 disables)")
+	rootCmd.PersistentFlags().DurationVar(&globalFlagDNSCacheTTL, "dns-cache-ttl", 5*time.Minute, "how long DNS answers are shared between threads and retries (0 disables the cache)")
+	rootCmd.PersistentFlags().BoolVar(&globalFlagOffline, "offline", false, "ignore datasets downloaded by update and use the embedded snapshot")
+	rootCmd.PersistentFlags().BoolVar(&globalFlagRecord, "record", false, "append successful results to the result store for stats and history")
+	rootCmd.PersistentFlags().StringSliceVar(&globalFlagTags, "tag", nil, "label this run, tags are saved with recorded results and error log entries (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&globalFlagMaxBandwidth, "max-bandwidth", "", "cap the total bytes sent and received across all threads e.g. 5mbps, 800kbps or 1mb/s")
+	rootCmd.PersistentFlags().StringArrayVar(&globalFlagVia, "via", nil, "upstream proxy to dial through e.g. socks5://127.0.0.1:1080 or http://127.0.0.1:8080, repeat to chain hops in order")
+	rootCmd.PersistentFlags().StringSliceVar(&globalFlagEnrich, "enrich", nil, "annotate result IPs - whois (RDAP netname, org, country and abuse contact) and/or blocklist")
+	rootCmd.PersistentFlags().StringVar(&globalFlagRDAPServer, "rdap-server", "https://rdap.org", "RDAP bootstrap server used by --enrich whois")
+	rootCmd.PersistentFlags().Float64Var(&globalFlagRDAPRate, "rdap-rate", 1, "maximum RDAP lookups per second")
+	rootCmd.PersistentFlags().StringArrayVar(&globalFlagBlocklists, "blocklist", nil, "blocklist feed url or file for --enrich blocklist, repeatable (default Spamhaus DROP and FireHOL level1)")
+	rootCmd.PersistentFlags().DurationVar(&globalFlagSkipRecent, "skip-recent", 0, "skip hosts already probed with the same parameters within this window e.g. 24h")
+	rootCmd.PersistentFlags().BoolVar(&globalFlagForce, "force", false, "rescan hosts that --skip-recent would skip")
+	rootCmd.PersistentFlags().BoolVar(&globalFlagSkipExisting, "skip-existing", false, "skip hosts already listed in the output file, for incremental re-runs of a large list")
+	rootCmd.PersistentFlags().BoolVar(&globalFlagResume, "resume", false, "continue an interrupted scan, skipping hosts it already finished")
+	rootCmd.PersistentFlags().Float64Var(&globalFlagRate, "rate", 0, "cap scan attempts per second across all threads (0 means unlimited)")
+	rootCmd.PersistentFlags().DurationVar(&globalFlagPace, "pace", 0, "minimum gap between probes to the same IP e.g. 500ms")
+	rootCmd.PersistentFlags().DurationVar(&globalFlagJitter, "jitter", 0, "add up to this much random delay between probes to the same IP")
+	rootCmd.PersistentFlags().StringVar(&globalFlagSink, "sink", "", "also send each result as JSON to a collector: an http(s) URL (one POST per result) or a TCP host:port")
+	rootCmd.PersistentFlags().StringVar(&globalFlagMetrics, "metrics", "", "serve Prometheus metrics on this address e.g. :9090")
+	rootCmd.PersistentFlags().StringVar(&globalFlagFailedOutput, "failed-output", "", "write hosts that produced no result to this file with a reason (dns-fail, connect-timeout, reset, handshake-fail...)")
+	rootCmd.PersistentFlags().BoolVar(&globalFlagKnownFirst, "known-first", false, "scan hosts that succeeded in earlier recorded runs before the rest of the list")
+	rootCmd.PersistentFlags().StringVar(&globalFlagShard, "shard", "", "scan only part i of n of the input e.g. 2/4, so several machines can split one scan given the same input")
+	rootCmd.PersistentFlags().BoolVar(&globalFlagInterleave, "interleave", false, "spread hosts from the same /24 across the scan instead of probing them back to back")
+	rootCmd.PersistentFlags().DurationVar(&globalFlagMaxRuntime, "max-runtime", 0, "stop handing out hosts and finish up after this long e.g. 30m")
+	rootCmd.PersistentFlags().BoolVar(&globalFlagLogJSON, "log-json", false, "print engine events (start, progress, success, error, finish) as JSON lines on stdout, other output goes to stderr")
+	rootCmd.PersistentFlags().IntVar(&globalFlagMaxSuccess, "max-success", 0, "stop the scan once this many working hosts are found (0 scans everything)")
+	rootCmd.PersistentFlags().IntVar(&globalFlagRetries, "retries", 0, "retry hosts that hit a transient failure (timeout, reset) up to this many times")
+	rootCmd.PersistentFlags().DurationVar(&globalFlagRetryBackoff, "retry-backoff", 500*time.Millisecond, "delay before the first retry, doubled on each further attempt")
+	rootCmd.PersistentFlags().StringVar(&globalFlagStore, "store", "", "result store file (default results.jsonl in the bugscanx-go config directory)")
 }
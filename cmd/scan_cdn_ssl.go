@@ -5,6 +5,8 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"io"
+	"iter"
 	"net"
 	"strconv"
 	"strings"
@@ -33,6 +35,8 @@ var (
 	cdnSSLFlagScheme            string
 	cdnSSLFlagProtocol          string
 	cdnSSLFlagPayload           string
+	cdnSSLFlagHeaders           []string
+	cdnSSLHeaders               []requestHeader
 	cdnSSLFlagTimeout           int
 	cdnSSLFlagOutput            string
 )
@@ -50,7 +54,8 @@ func init() {
 	cdnSSLCmd.Flags().StringVar(&cdnSSLFlagPath, "path", "[scheme][bug]", "request path")
 	cdnSSLCmd.Flags().StringVar(&cdnSSLFlagScheme, "scheme", "ws://", "request scheme")
 	cdnSSLCmd.Flags().StringVar(&cdnSSLFlagProtocol, "protocol", "HTTP/1.1", "request protocol")
-	cdnSSLCmd.Flags().StringVar(&cdnSSLFlagPayload, "payload", "[method] [path] [protocol][crlf]Host: [host][crlf]Upgrade: websocket[crlf][crlf]", "request payload for sending throught cdn proxy")
+	cdnSSLCmd.Flags().StringArrayVarP(&cdnSSLFlagHeaders, "header", "H", nil, "extra header added to the payload's header block, repeatable e.g. -H \"X-Online-Host: [host]\"")
+	cdnSSLCmd.Flags().StringVar(&cdnSSLFlagPayload, "payload", "[method] [path] [protocol][crlf]Host: [host][crlf]Upgrade: websocket[crlf][crlf]", "request payload for sending throught cdn proxy, supports [ua], [urlencode:...], [b64:...] and [hexlify:...]")
 	cdnSSLCmd.Flags().IntVar(&cdnSSLFlagTimeout, "timeout", 3, "handshake timeout")
 	cdnSSLCmd.Flags().StringVarP(&cdnSSLFlagOutput, "output", "o", "", "output result")
 }
@@ -71,8 +76,19 @@ func scanCDNSSL(ctx *queuescanner.Ctx, host string) {
 
 	address := net.JoinHostPort(host, strconv.Itoa(cdnSSLFlagProxyPort))
 
-	conn, err := net.DialTimeout("tcp", address, 3*time.Second)
+	start := time.Now()
+
+	hostCtx, hostCancel := hostContext(ctx.Context())
+	defer hostCancel()
+
+	dialCtx, dialCancel := fallbackContext(hostCtx, 3*time.Second)
+	defer dialCancel()
+
+	ctx.Pace(host)
+	conn, err := dialContext(dialCtx, "tcp", address)
 	if err != nil {
+		logFailure("cdn-ssl", host, host, address, withPhase("dial", err), start)
+		retryOnTransient(ctx, host, err)
 		return
 	}
 	defer conn.Close()
@@ -82,26 +98,33 @@ func scanCDNSSL(ctx *queuescanner.Ctx, host string) {
 		InsecureSkipVerify: true,
 	})
 
-	handshakeCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cdnSSLFlagTimeout)*time.Second)
+	handshakeCtx, cancel := context.WithTimeout(hostCtx, time.Duration(cdnSSLFlagTimeout)*time.Second)
 	defer cancel()
 
 	err = tlsConn.HandshakeContext(handshakeCtx)
 	if err != nil {
+		logFailure("cdn-ssl", host, host, address, withPhase("tls", err), start)
+		retryOnTransient(ctx, host, err)
 		return
 	}
 
-	timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	timeoutCtx, timeoutCancel := fallbackContext(hostCtx, 10*time.Second)
 	defer timeoutCancel()
 
-	resultCh := make(chan bool)
+	resultCh := make(chan bool, 1)
 
 	go func() {
-		payload := getScanCDNSSLPayloadDecoded(bug)
+		defer func() { resultCh <- true }()
+
+		payload := insertPayloadHeaders(getScanCDNSSLPayloadDecoded(bug), cdnSSLHeaders)
 		payload = strings.ReplaceAll(payload, "[host]", cdnSSLFlagTarget)
 		payload = strings.ReplaceAll(payload, "[crlf]", "\r\n")
+		payload = expandPayloadFuncs(payload)
 
-		_, err = tlsConn.Write([]byte(payload))
+		_, err := tlsConn.Write([]byte(payload))
 		if err != nil {
+			logFailure("cdn-ssl", host, host, address, withPhase("write", err), start)
+			retryOnTransient(ctx, host, err)
 			return
 		}
 
@@ -114,28 +137,49 @@ func scanCDNSSL(ctx *queuescanner.Ctx, host string) {
 			if line == "" {
 				break
 			}
-			if isPrefix || strings.HasPrefix(line, "Location") || strings.HasPrefix(line, "Server") {
+			if isPrefix || isReportedHeader(line) {
 				isPrefix = false
 				responseLines = append(responseLines, line)
 			}
 		}
 
-		if len(responseLines) == 0 || !strings.Contains(responseLines[0], " 101 ") {
-			ctx.Log(fmt.Sprintf("%-32s  %s", address, strings.Join(responseLines, " -- ")))
+		if len(responseLines) == 0 {
+			err := scanner.Err()
+			if err == nil {
+				err = io.EOF
+			}
+			logFailure("cdn-ssl", host, host, address, withPhase("read", err), start)
+			retryOnTransient(ctx, host, err)
 			return
 		}
 
-		formatted := fmt.Sprintf("%-32s  %s", address, strings.Join(responseLines, " -- "))
-		ctx.ScanSuccess(formatted)
-		ctx.Log(formatted)
+		if !strings.Contains(responseLines[0], " 101 ") {
+			logFailureClass("cdn-ssl", host, host, address, "response", "unexpected-status", nil, start)
+			ctx.Log(fmt.Sprintf("%-32s  %s", address, strings.Join(responseLines, " -- ")))
+			return
+		}
 
-		resultCh <- true
+		info := enrichIP(host)
+		formatted := fmt.Sprintf("%-32s  %s", address, strings.Join(responseLines, " -- ")) + info.suffix()
+		result := &queuescanner.Result{
+			Host:    host,
+			Port:    strconv.Itoa(cdnSSLFlagProxyPort),
+			Status:  101,
+			Latency: time.Since(start),
+			Extra:   info.merge(map[string]string{"target": cdnSSLFlagTarget}),
+			Line:    formatted,
+		}
+		ctx.ScanSuccess(result)
+		ctx.Log(colorStatus(formatted, 101))
+		reportResult("cdn-ssl", host, result)
 	}()
 
 	select {
 	case <-resultCh:
 		return
 	case <-timeoutCtx.Done():
+		logFailureClass("cdn-ssl", host, host, address, "response", "timeout", nil, start)
+		ctx.Retry(host)
 		return
 	}
 }
@@ -146,6 +190,7 @@ func getScanCDNSSLPayloadDecoded(bug ...string) string {
 	payload = strings.ReplaceAll(payload, "[path]", cdnSSLFlagPath)
 	payload = strings.ReplaceAll(payload, "[scheme]", cdnSSLFlagScheme)
 	payload = strings.ReplaceAll(payload, "[protocol]", cdnSSLFlagProtocol)
+	payload = strings.ReplaceAll(payload, "[ua]", globalFlagUserAgent)
 	if len(bug) > 0 {
 		payload = strings.ReplaceAll(payload, "[bug]", bug[0])
 	}
@@ -155,6 +200,14 @@ func getScanCDNSSLPayloadDecoded(bug ...string) string {
 func runScanCDNSSL(cmd *cobra.Command, args []string) {
 	var proxyHosts []string
 
+	cdnSSLFlagTarget = toASCIIHost(cdnSSLFlagTarget)
+
+	var err error
+	cdnSSLHeaders, err = parseRequestHeaders(cdnSSLFlagHeaders)
+	if err != nil {
+		fatal(err)
+	}
+
 	if cdnSSLFlagProxyHost != "" {
 		proxyHosts = append(proxyHosts, cdnSSLFlagProxyHost)
 	}
@@ -167,16 +220,19 @@ func runScanCDNSSL(cmd *cobra.Command, args []string) {
 		proxyHosts = append(proxyHosts, lines...)
 	}
 
+	var cidrHosts iter.Seq[string]
+	var cidrTotal int
 	if cdnSSLFlagProxyCIDR != "" {
-		cidrHosts, err := IPsFromCIDR(cdnSSLFlagProxyCIDR)
+		var err error
+		cidrHosts, cidrTotal, err = IPsFromCIDR(cdnSSLFlagProxyCIDR)
 		if err != nil {
 			fatal(err)
 		}
-		proxyHosts = append(proxyHosts, cidrHosts...)
 	}
 
-	qs := queuescanner.New(globalFlagThreads, scanCDNSSL)
+	qs := newScanner(scanCDNSSL)
 	fmt.Printf("%s\n\n", getScanCDNSSLPayloadDecoded())
-	qs.SetOptions(proxyHosts, cdnSSLFlagOutput, globalFlagStatInterval)
-	qs.Start()
+	startScanSource(qs, proxyHosts, cidrHosts, cidrTotal, "cidr "+cdnSSLFlagProxyCIDR, scanOutputFile(cdnSSLFlagOutput))
+
+	writeSortedOutput(cdnSSLFlagOutput)
 }
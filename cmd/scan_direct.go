@@ -1,12 +1,28 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"html"
+	"io"
 	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -25,49 +41,152 @@ var (
 	directFlagPort           string
 	directFlagOutput         string
 	directFlagHideLocation   string
+	directFlagMatchCode      string
+	directFlagFilterCode     string
 	directFlagMethod         string
 	directFlagTimeoutConnect int
 	directFlagTimeoutRequest int
 	directFlagTimeoutDNS     int
+	directFlagReadBody       bool
+	directFlagMatchString    string
+	directFlagMatchRegex     string
+	directFlagMaxBody        int64
+	directFlagMaxIPs         int
+	directFlagIPv4           bool
+	directFlagIPv6           bool
+	directFlagScheme         string
+	directFlagTLSPorts       string
+	directFlagHTTPPorts      string
+	directFlagPath           string
+	directFlagHTTPVersion    string
+	directFlagHeaders        []string
+	directFlagSplitPorts     bool
+	directFlagCheckWS        bool
+	directFlagKeepAlive      bool
+	directFlagQUICProbe      bool
+	directFlagPayload        string
+	directFlagRedirects      int
+	directFlagTitle          bool
+	directFlagVHostTest      string
+	directFlagOutputFormat   string
+	directFlagRetry          int
+	directFlagSaveHeaders    string
+	directFlagResolve        []string
+	directFlagFavicon        bool
+	directFlagHAR            string
+	directFlagTopPorts       string
+	directFlagExcludePorts   string
+)
+
+var (
+	directPorts     []string
+	directTLSPorts  []string
+	directHTTPPorts []string
+	directHeaders   []requestHeader
+	directMatch     *regexp.Regexp
+	directShowCodes []string
+	directResolve   map[string][]net.IP
+	directHideCodes []string
 )
 
 func init() {
 	rootCmd.AddCommand(directCmd)
 
 	directCmd.Flags().StringVarP(&directFlagFilename, "filename", "f", "", "domain list filename")
-	directCmd.Flags().StringVarP(&directFlagPort, "port", "p", "80", "port(s) to scan - single (80) or multiple comma-separated (80,443,8080)")
+	directCmd.Flags().StringVarP(&directFlagPort, "port", "p", "80", "port(s) to scan - single (80), comma-separated (80,443,8080) or ranges (80,443,8000-8100)")
+	directCmd.Flags().StringVar(&directFlagTopPorts, "top-ports", "", "use a port preset - web, mail or all-common")
+	directCmd.Flags().StringVar(&directFlagExcludePorts, "exclude-ports", "", "comma-separated ports to skip")
 	directCmd.Flags().StringVarP(&directFlagOutput, "output", "o", "", "output result")
+	directCmd.Flags().StringVar(&directFlagOutputFormat, "output-format", "table", "output file format - table, json (one object per line) or csv (with a header row)")
+	directCmd.Flags().StringVar(&directFlagScheme, "scheme", "auto", "request scheme - http, https or auto (TLS on --tls-ports, plain on --http-ports, detected with a handshake on any other port; a listed port that answers in the other protocol is retried in it and shown as fallback:)")
+	directCmd.Flags().StringVar(&directFlagTLSPorts, "tls-ports", "443,8443,9443,10443", "ports that always use TLS when scheme is auto")
+	directCmd.Flags().StringVar(&directFlagHTTPPorts, "http-ports", "80,8080", "ports that never use TLS when scheme is auto")
+	directCmd.Flags().BoolVar(&directFlagSplitPorts, "split-ports", false, "write results to one output file per port e.g. output-443.txt")
+	directCmd.Flags().BoolVar(&directFlagCheckWS, "check-ws", false, "also send a websocket upgrade request and record its status (101 means upgraded)")
+	directCmd.Flags().StringVar(&directFlagSaveHeaders, "save-headers", "", "write each hit's full response headers to a file per host, address and port in this directory")
+	directCmd.Flags().StringVar(&directFlagHAR, "har", "", "export the captured request/response pairs to this HAR file")
+	directCmd.Flags().BoolVar(&directFlagKeepAlive, "keep-alive", false, "send a second request on the same connection and record its warm latency (no means the connection was not reused)")
+	directCmd.Flags().StringVar(&directFlagVHostTest, "vhost-test", "", "resend the request with Host set to these comma-separated bug hosts and to the IP, and report whether the answers differ from the domain's")
+	directCmd.Flags().BoolVar(&directFlagFavicon, "favicon", false, "also fetch /favicon.ico and record its mmh3 hash, as searched with Shodan's http.favicon.hash")
+	directCmd.Flags().BoolVar(&directFlagQUICProbe, "quic-probe", false, "also send a QUIC version probe to the same UDP port and record the versions it offers, without a handshake (no means it didn't answer)")
 	directCmd.Flags().StringVarP(&directFlagMethod, "method", "m", "HEAD", "HTTP method to use")
+	directCmd.Flags().StringVar(&directFlagPath, "path", "/", "request path and query, supports [host], [ip] and [port] placeholders")
+	directCmd.Flags().StringVar(&directFlagPayload, "payload", "", "send this raw request instead of a generated one, with the same placeholders as proxy e.g. \"[method] [path] [protocol][crlf]Host: [host][crlf][crlf]\"; -H headers go at the end of its header block")
+	directCmd.Flags().StringArrayVarP(&directFlagHeaders, "header", "H", nil, "extra request header, repeatable e.g. -H \"X-Online-Host: example.com\"")
+	directCmd.Flags().StringVar(&directFlagHTTPVersion, "http-version", "1.1", "HTTP version - 1.0, 1.1 or 2 (negotiated via ALPN on TLS ports, 1.1 otherwise; the negotiated protocol is shown as proto:)")
+	directCmd.Flags().StringVar(&directFlagMatchCode, "match-code", "", "only show these status codes, comma-separated, 3xx matches a whole class e.g. 200,3xx")
+	directCmd.Flags().StringVar(&directFlagFilterCode, "filter-code", "", "hide these status codes, comma-separated, 5xx matches a whole class e.g. 403,5xx")
+	directCmd.Flags().IntVar(&directFlagRedirects, "follow-redirects", 0, "follow up to this many Location redirects and report where they end, as -> status url")
 	directCmd.Flags().StringVar(&directFlagHideLocation, "skip", "https://jio.com/BalanceExhaust", "skip results with this Location header")
+	directCmd.Flags().IntVar(&directFlagRetry, "retry", 0, "re-probe a port this many times right away when it times out or resets, waiting --retry-backoff between tries, before counting it as failed")
 	directCmd.Flags().IntVar(&directFlagTimeoutConnect, "timeout-connect", 5, "TCP connect timeout in seconds")
 	directCmd.Flags().IntVar(&directFlagTimeoutRequest, "timeout-request", 10, "Overall request timeout in seconds")
 	directCmd.Flags().IntVar(&directFlagTimeoutDNS, "timeout-dns", 5, "DNS lookup timeout in seconds")
+	directCmd.Flags().BoolVar(&directFlagReadBody, "read-body", false, "read the response body and report its actual size")
+	directCmd.Flags().BoolVar(&directFlagTitle, "title", false, "read the start of the body and record the page <title> (sends GET unless --method is set)")
+	directCmd.Flags().StringVar(&directFlagMatchString, "match-string", "", "only report responses whose body contains this text (sends GET unless --method is set)")
+	directCmd.Flags().StringVar(&directFlagMatchRegex, "match-regex", "", "only report responses whose body matches this regular expression (sends GET unless --method is set)")
+	directCmd.Flags().StringArrayVar(&directFlagResolve, "resolve", nil, "probe host:port on these addresses instead of resolving it, keeping the Host header and SNI, repeatable e.g. --resolve example.com:443:104.16.1.1,104.16.2.1")
+	directCmd.Flags().BoolVarP(&directFlagIPv4, "ipv4", "4", false, "only resolve and probe IPv4 addresses")
+	directCmd.Flags().BoolVarP(&directFlagIPv6, "ipv6", "6", false, "only resolve and probe IPv6 addresses")
+	directCmd.Flags().IntVar(&directFlagMaxIPs, "max-ips", 1, "maximum resolved IPs to probe per host (0 for all)")
+	directCmd.Flags().Int64Var(&directFlagMaxBody, "max-body", 1<<20, "maximum body bytes to read with --read-body")
 }
 
+// parsePorts expands a port spec of single ports and ranges, e.g.
+// 80,443,8000-8100, keeping the first occurrence of each port.
 func parsePorts(portSpec string) ([]string, error) {
 	var ports []string
+	seen := make(map[int]bool)
 
 	parts := strings.Split(portSpec, ",")
 
 	for _, part := range parts {
 		part = strings.TrimSpace(part)
 
-		port, err := strconv.Atoi(part)
+		first, last, isRange := strings.Cut(part, "-")
+		low, err := parsePort(first)
 		if err != nil {
-			return nil, fmt.Errorf("invalid port: %s", part)
+			return nil, err
 		}
-
-		if port < 1 || port > 65535 {
-			return nil, fmt.Errorf("port must be between 1 and 65535: %d", port)
+		high := low
+		if isRange {
+			if high, err = parsePort(last); err != nil {
+				return nil, err
+			}
+			if high < low {
+				return nil, fmt.Errorf("invalid port range: %s", part)
+			}
 		}
 
-		ports = append(ports, part)
+		for port := low; port <= high; port++ {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, strconv.Itoa(port))
+			}
+		}
 	}
 
 	return ports, nil
 }
 
-func extractHTTPHeaders(response string) (statusCode int, server string, location string) {
+func parsePort(s string) (int, error) {
+	s = strings.TrimSpace(s)
+
+	port, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port: %s", s)
+	}
+
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port must be between 1 and 65535: %d", port)
+	}
+
+	return port, nil
+}
+
+func extractHTTPHeaders(response string) (statusCode int, server string, location string, contentLength int64) {
+	contentLength = -1
 	lines := strings.Split(response, "\n")
 
 	if len(lines) > 0 {
@@ -89,107 +208,1090 @@ func extractHTTPHeaders(response string) (statusCode int, server string, locatio
 			server = strings.TrimSpace(line[7:])
 		} else if strings.HasPrefix(strings.ToLower(line), "location:") {
 			location = strings.TrimSpace(line[9:])
+		} else if strings.HasPrefix(strings.ToLower(line), "content-length:") {
+			if length, err := strconv.ParseInt(strings.TrimSpace(line[15:]), 10, 64); err == nil {
+				contentLength = length
+			}
+		}
+	}
+
+	return statusCode, server, location, contentLength
+}
+
+func parseHeaderMap(response string) map[string]string {
+	headers := make(map[string]string)
+
+	lines := strings.Split(response, "\n")
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
 		}
+		headers[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
 	}
 
-	return statusCode, server, location
+	return headers
+}
+
+func formatSize(size int64) string {
+	if size < 0 {
+		return "-"
+	}
+	return strconv.FormatInt(size, 10)
 }
 
 func scanDirect(ctx *queuescanner.Ctx, host string) {
-	ports, err := parsePorts(directFlagPort)
-	if err != nil {
+	start := time.Now()
+
+	hostCtx, hostCancel := hostContext(ctx.Context())
+	defer hostCancel()
+
+	// a retry rescans every port and address of the host, so only ask for
+	// one when nothing was reported yet, or the hits would be reported twice
+	reported := false
+	var transient error
+	defer func() {
+		if !reported && transient != nil {
+			retryOnTransient(ctx, host, transient)
+		}
+	}()
+	scanPort := func(ipStr string, port string) {
+		ok, err := scanDirectPort(ctx, hostCtx, host, ipStr, port)
+		if ok {
+			reported = true
+		} else if transient == nil && isRetryable(err) {
+			transient = err
+		}
+	}
+
+	var lookupPorts []string
+	for _, port := range directPorts {
+		if ips, ok := directResolve[resolveKey(host, port)]; ok {
+			for _, ip := range ips {
+				scanPort(ip.String(), port)
+			}
+			continue
+		}
+		lookupPorts = append(lookupPorts, port)
+	}
+	if len(lookupPorts) == 0 {
 		return
 	}
 
-	lookupCtx, cancel := context.WithTimeout(context.Background(), time.Duration(directFlagTimeoutDNS)*time.Second)
+	lookupCtx, cancel := context.WithTimeout(hostCtx, time.Duration(directFlagTimeoutDNS)*time.Second)
 	defer cancel()
 
-	ips, err := net.DefaultResolver.LookupIP(lookupCtx, "ip4", host)
-	if err != nil || len(ips) == 0 {
+	ips, err := lookupIP(lookupCtx, directFamily(), host)
+	if err != nil {
+		logFailure("direct", host, host, "", withPhase("dns", err), start)
+		transient = err
+		return
+	}
+	if len(ips) == 0 {
+		logFailureClass("direct", host, host, "", "dns", "no-address", nil, start)
 		return
 	}
 
-	ip := ips[0]
-	ipStr := ip.String()
+	// dual-stack hosts try IPv4 first, so --max-ips 1 keeps picking the same address
+	sortIPv4First(ips)
 
-	for _, port := range ports {
-		useTLS := false
-		commonHTTPSPorts := []string{"443", "8443", "9443", "10443"}
-		for _, httpsPort := range commonHTTPSPorts {
-			if port == httpsPort {
-				useTLS = true
-				break
-			}
+	if directFlagMaxIPs > 0 && len(ips) > directFlagMaxIPs {
+		ips = ips[:directFlagMaxIPs]
+	}
+
+	for _, ip := range ips {
+		for _, port := range lookupPorts {
+			scanPort(ip.String(), port)
 		}
+	}
+}
+
+// scanDirectPort probes one address and port of host. It reports whether a
+// result was recorded, and the error when the exchange itself failed.
+func scanDirectPort(ctx *queuescanner.Ctx, hostCtx context.Context, host string, ipStr string, port string) (bool, error) {
+	start := time.Now()
+
+	useTLS := directFlagScheme == "https"
+	detectTLS := false
+	if directFlagScheme == "auto" {
+		useTLS = slices.Contains(directTLSPorts, port)
+		detectTLS = !useTLS && !slices.Contains(directHTTPPorts, port)
+	}
+
+	method := directFlagMethod
+	if method == "" {
+		method = "HEAD"
+	}
+
+	path := strings.NewReplacer("[host]", host, "[ip]", ipStr, "[port]", port).Replace(directFlagPath)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
 
-		address := fmt.Sprintf("%s:%s", ipStr, port)
-		network := "tcp4"
+	tryTLS := useTLS || detectTLS
+	otherProtocol := func(err error) bool {
+		return directFlagScheme == "auto" && ((tryTLS && notTLS(err)) || (!tryTLS && notPlain(err)))
+	}
+
+	var reply directReply
+	var err error
+	backoff := globalFlagRetryBackoff
+	for attempt := 0; ; attempt++ {
+		ctx.Pace(ipStr)
+		reply, err = directExchange(hostCtx, host, ipStr, port, tryTLS, method, path)
+		if err == nil || attempt >= directFlagRetry || !isRetryable(err) || otherProtocol(err) || !sleepContext(hostCtx, backoff) {
+			break
+		}
+		backoff *= 2
+	}
 
-		dialer := &net.Dialer{
-			Timeout: time.Duration(directFlagTimeoutConnect) * time.Second,
+	fellBack := false
+	if err != nil && otherProtocol(err) {
+		// the port speaks the other protocol, so ask again before giving up on it
+		if fallback, fallbackErr := directExchange(hostCtx, host, ipStr, port, !tryTLS, method, path); fallbackErr == nil {
+			reply, err = fallback, nil
+			fellBack = !detectTLS
 		}
+	}
+	if err != nil {
+		logFailure("direct", host, host, net.JoinHostPort(ipStr, port), err, start)
+		return false, err
+	}
+
+	useTLS = reply.isTLS
+	response, body, ttfb, size := reply.response, reply.body, reply.ttfb, reply.size
+	connectTime, tlsTime, tlsState := reply.connectTime, reply.tlsTime, reply.tlsState
 
-		var conn net.Conn
-		if useTLS {
-			conn, err = tls.DialWithDialer(dialer, network, address, &tls.Config{
-				InsecureSkipVerify: true,
-				ServerName:         host,
-			})
+	handshakeTime := "-"
+	if useTLS {
+		handshakeTime = formatLatency(tlsTime)
+	}
+
+	if directMatching() && !directBodyMatches(body) {
+		logFailureClass("direct", host, host, net.JoinHostPort(ipStr, port), "match", "no-match", nil, start)
+		return false, nil
+	}
+
+	statusCode, server, location, contentLength := extractHTTPHeaders(response)
+	if size < 0 {
+		size = contentLength
+	}
+
+	if directFlagHideLocation != "" && location == directFlagHideLocation {
+		return false, nil
+	}
+
+	if (directShowCodes != nil && !matchStatus(statusCode, directShowCodes)) || matchStatus(statusCode, directHideCodes) {
+		return false, nil
+	}
+
+	if globalFlagUniqueIP && !ctx.ClaimUnique(ipStr) {
+		return false, nil
+	}
+
+	headers := parseHeaderMap(response)
+	fingerprint := fingerprintServer(statusCode, headers)
+
+	hostWithPort := net.JoinHostPort(displayHost(host), port)
+	formatted := fmt.Sprintf("%-15s  %-3d   %-16s  %-14s  %-7s  %-7s  %-7s  %-8s  %s", ipStr, statusCode, server, fingerprint, formatLatency(connectTime), handshakeTime, formatLatency(ttfb), formatSize(size), hostWithPort)
+
+	extra := map[string]string{"fingerprint": fingerprint}
+	if contentLength >= 0 {
+		extra["content_length"] = strconv.FormatInt(contentLength, 10)
+	}
+	if directFlagHTTPVersion == "2" {
+		// whether the server took h2 over ALPN or we fell back to http/1.1
+		formatted += "  proto:" + reply.proto
+		extra["proto"] = reply.proto
+	}
+
+	if provider, source := detectCDN(hostCtx, host, ipStr, headers); provider != "" {
+		formatted += "  cdn:" + provider + "(" + source + ")"
+		extra["cdn"] = provider
+		extra["cdn_source"] = source
+	}
+
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	extra["scheme"] = scheme
+	if fellBack {
+		formatted += "  fallback:" + scheme
+	}
+
+	if useTLS {
+		version := strings.ReplaceAll(tls.VersionName(tlsState.Version), " ", "")
+		cipher := tls.CipherSuiteName(tlsState.CipherSuite)
+		formatted += "  tls:" + version + "/" + cipher
+		extra["tls_version"] = version
+		extra["tls_cipher"] = cipher
+	}
+
+	if directFlagTitle {
+		if title := pageTitle(body); title != "" {
+			formatted += "  title:" + strconv.Quote(title)
+			extra["title"] = title
 		} else {
-			conn, err = dialer.Dial(network, address)
+			formatted += "  title:-"
 		}
-		if err != nil {
-			continue
+	}
+
+	if directFlagRedirects > 0 && location != "" {
+		final, finalStatus, hops := followRedirects(hostCtx, directURL(useTLS, host, port, path), method, statusCode, location)
+		finalText := "-"
+		if finalStatus != 0 {
+			finalText = strconv.Itoa(finalStatus)
 		}
+		formatted += "  -> " + finalText + " " + final.String()
+		extra["final_url"] = final.String()
+		extra["final_status"] = finalText
+		extra["redirects"] = strconv.Itoa(hops)
+	}
+
+	if directFlagVHostTest != "" {
+		verdict, answers := checkVirtualHosts(hostCtx, host, ipStr, port, useTLS, method, path, statusCode, location, contentLength)
+		formatted += "  vhost:" + verdict + "[" + answers + "]"
+		extra["vhost"] = verdict
+		extra["vhost_answers"] = answers
+	}
+
+	if directFlagCheckWS {
+		formatted += "  ws:" + checkWebSocket(hostCtx, host, ipStr, port, useTLS, path)
+	}
 
-		conn.SetDeadline(time.Now().Add(time.Duration(directFlagTimeoutRequest) * time.Second))
+	if directFlagKeepAlive {
+		formatted += "  ka:" + checkKeepAlive(hostCtx, host, ipStr, port, useTLS, method, path)
+	}
 
-		method := directFlagMethod
-		if method == "" {
-			method = "HEAD"
+	if directFlagFavicon {
+		favicon := checkFavicon(hostCtx, host, ipStr, port, useTLS)
+		formatted += "  favicon:" + favicon
+		if favicon != "-" {
+			extra["favicon_mmh3"] = favicon
 		}
+	}
 
-		httpRequest := fmt.Sprintf("%s / HTTP/1.1\r\nHost: %s\r\nUser-Agent: bugscanx-go/1.0\r\nConnection: close\r\n\r\n", method, host)
+	if directFlagQUICProbe {
+		quic := checkQUIC(hostCtx, ipStr, port)
+		formatted += "  quic:" + quic
+		extra["quic"] = quic
+	}
 
-		_, err = conn.Write([]byte(httpRequest))
-		if err != nil {
+	info := enrichIP(ipStr)
+	formatted += info.suffix()
+
+	result := &queuescanner.Result{
+		Host:     host,
+		IP:       ipStr,
+		Port:     port,
+		Status:   statusCode,
+		Server:   server,
+		Location: location,
+		Latency:  connectTime + ttfb,
+		Extra:    info.merge(extra),
+		Line:     formatted,
+	}
+	ctx.ScanSuccessTagged("port "+port, result)
+	ctx.Log(colorStatus(formatted, statusCode))
+	reportResult("direct", host, result)
+
+	if directFlagSaveHeaders != "" {
+		if err := saveResponseHeaders(directFlagSaveHeaders, directURL(useTLS, host, port, path).String(), host, ipStr, port, response); err != nil {
+			ctx.Log(fmt.Sprintf("save headers failed: %v", err))
+		}
+	}
+
+	if directFlagHAR != "" {
+		addHAREntry(directHAREntry(start, host, ipStr, port, useTLS, method, path, response, size, connectTime, tlsTime, ttfb))
+	}
+
+	return true, nil
+}
+
+func directHAREntry(start time.Time, host string, ipStr string, port string, useTLS bool, method string, path string, response string, size int64, connectTime time.Duration, tlsTime time.Duration, ttfb time.Duration) harEntry {
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+
+	httpVersion, status, statusText, headers := parseHARResponse(response)
+
+	requestVersion := httpVersion
+	requestHeaders := []harHeader{{Name: "Host", Value: host}, {Name: "User-Agent", Value: globalFlagUserAgent}}
+	if !strings.HasPrefix(httpVersion, "HTTP/2") {
+		requestVersion = "HTTP/1.1"
+		if directFlagHTTPVersion == "1.0" {
+			requestVersion = "HTTP/1.0"
+		}
+		requestHeaders = append(requestHeaders, harHeader{Name: "Connection", Value: "close"}, harHeader{Name: "Accept-Encoding", Value: "gzip, deflate"})
+	}
+
+	headerMap := parseHeaderMap(response)
+
+	ssl := int64(-1)
+	if useTLS {
+		ssl = tlsTime.Milliseconds()
+	}
+
+	// HAR counts the TLS handshake inside connect
+	connect := connectTime.Milliseconds()
+	if ssl > 0 {
+		connect += ssl
+	}
+
+	return harEntry{
+		StartedDateTime: harTime(start),
+		Time:            (connectTime + tlsTime + ttfb).Milliseconds(),
+		Request: harRequest{
+			Method:      method,
+			URL:         scheme + "://" + net.JoinHostPort(host, port) + path,
+			HTTPVersion: requestVersion,
+			Headers:     requestHeaders,
+			QueryString: []harHeader{},
+			Cookies:     []harHeader{},
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: harResponse{
+			Status:      status,
+			StatusText:  statusText,
+			HTTPVersion: httpVersion,
+			Headers:     headers,
+			Cookies:     []harHeader{},
+			Content:     harContent{Size: max(size, 0), MimeType: headerMap["content-type"]},
+			RedirectURL: headerMap["location"],
+			HeadersSize: -1,
+			BodySize:    size,
+		},
+		Timings: harTimings{
+			Blocked: -1,
+			DNS:     -1,
+			Connect: connect,
+			SSL:     ssl,
+			Send:    0,
+			Wait:    ttfb.Milliseconds(),
+			Receive: 0,
+		},
+		ServerIPAddress: ipStr,
+	}
+}
+
+type directReply struct {
+	response    string
+	body        []byte
+	size        int64
+	connectTime time.Duration
+	tlsTime     time.Duration
+	ttfb        time.Duration
+	isTLS       bool
+	tlsState    tls.ConnectionState
+	proto       string
+}
+
+// directExchange connects to the port and makes the request, over HTTP/2
+// when --http-version 2 gets h2 through ALPN and HTTP/1 otherwise.
+func directExchange(hostCtx context.Context, host string, ipStr string, port string, useTLS bool, method string, path string) (reply directReply, err error) {
+	var nextProtos []string
+	if useTLS && directFlagHTTPVersion == "2" {
+		nextProtos = []string{"h2", "http/1.1"}
+	}
+
+	conn, connectTime, tlsTime, err := directConnect(hostCtx, host, ipStr, port, useTLS, nextProtos)
+	if err != nil {
+		return reply, err
+	}
+	defer conn.Close()
+
+	reply.connectTime, reply.tlsTime, reply.proto = connectTime, tlsTime, "http/1.1"
+
+	tlsConn, isTLS := conn.(*tls.Conn)
+	if isTLS {
+		reply.isTLS, reply.tlsState = true, tlsConn.ConnectionState()
+	}
+
+	if isTLS && reply.tlsState.NegotiatedProtocol == "h2" {
+		reply.proto = "h2"
+		reply.response, reply.body, reply.ttfb, reply.size, err = directRequestH2(hostCtx, tlsConn, host, method, path)
+	} else {
+		var payload string
+		if directFlagPayload != "" {
+			payload = directPayload(host, ipStr, port, method, path)
+		}
+		reply.response, reply.body, reply.ttfb, reply.size, err = directRequestH1(conn, host, method, path, payload)
+	}
+
+	return reply, err
+}
+
+func directConnect(hostCtx context.Context, host string, ipStr string, port string, useTLS bool, nextProtos []string) (conn net.Conn, connectTime time.Duration, handshakeTime time.Duration, err error) {
+	address := net.JoinHostPort(ipStr, port)
+	network := "tcp4"
+	if ip := net.ParseIP(ipStr); ip != nil && ip.To4() == nil {
+		network = "tcp6"
+	}
+
+	dialCtx, dialCancel := context.WithTimeout(hostCtx, time.Duration(directFlagTimeoutConnect)*time.Second)
+	defer dialCancel()
+
+	connectStart := time.Now()
+	conn, err = dialContext(dialCtx, network, address)
+	if err != nil {
+		return nil, 0, 0, withPhase("dial", err)
+	}
+	connectTime = time.Since(connectStart)
+
+	setHostDeadline(hostCtx, conn, time.Duration(directFlagTimeoutRequest)*time.Second)
+
+	if useTLS {
+		tlsConn := tls.Client(conn, &tls.Config{
+			InsecureSkipVerify: true,
+			ServerName:         host,
+			NextProtos:         nextProtos,
+		})
+
+		handshakeStart := time.Now()
+		if err := tlsConn.Handshake(); err != nil {
 			conn.Close()
+			return nil, 0, 0, withPhase("tls", err)
+		}
+		handshakeTime = time.Since(handshakeStart)
+
+		conn = tlsConn
+	}
+
+	return conn, connectTime, handshakeTime, nil
+}
+
+// notTLS reports whether a failed handshake means the server answered with
+// something other than TLS, as plain HTTP servers do, rather than a network
+// failure.
+func notTLS(err error) bool {
+	var pe *phaseError
+	if !errors.As(err, &pe) || pe.phase != "tls" {
+		return false
+	}
+
+	var recordErr tls.RecordHeaderError
+	return errors.As(err, &recordErr) || errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// notPlain reports whether a plain request got something other than HTTP
+// back, as TLS servers answer with an alert or by hanging up.
+func notPlain(err error) bool {
+	var pe *phaseError
+	if !errors.As(err, &pe) || pe.phase != "read" {
+		return false
+	}
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || strings.Contains(err.Error(), "malformed HTTP")
+}
+
+func checkWebSocket(hostCtx context.Context, host string, ipStr string, port string, useTLS bool, path string) string {
+	conn, _, _, err := directConnect(hostCtx, host, ipStr, port, useTLS, nil)
+	if err != nil {
+		return "-"
+	}
+	defer conn.Close()
+
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	httpRequest := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\nUser-Agent: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", path, hostHeader(host), globalFlagUserAgent, base64.StdEncoding.EncodeToString(key))
+
+	if _, err := conn.Write([]byte(httpRequest)); err != nil {
+		return "-"
+	}
+
+	buffer := make([]byte, 4096)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		return "-"
+	}
+
+	statusCode, _, _, _ := extractHTTPHeaders(string(buffer[:n]))
+	if statusCode == 0 {
+		return "-"
+	}
+
+	return strconv.Itoa(statusCode)
+}
+
+// checkVirtualHosts repeats the request with other Host headers, keeping the
+// domain as SNI, and compares status, Location and Content-Length with the
+// domain's own answer. A server that routes by Host answers them differently.
+func checkVirtualHosts(hostCtx context.Context, host string, ipStr string, port string, useTLS bool, method string, path string, status int, location string, contentLength int64) (verdict string, answers string) {
+	variants := append(strings.Split(directFlagVHostTest, ","), ipStr)
+
+	verdict = "same"
+	results := make([]string, 0, len(variants))
+	for _, variant := range variants {
+		variant = strings.TrimSpace(variant)
+		if variant == "" || strings.EqualFold(variant, host) {
 			continue
 		}
 
-		buffer := make([]byte, 4096)
-		n, err := conn.Read(buffer)
-		conn.Close()
+		answer := "-"
+		conn, _, _, err := directConnect(hostCtx, host, ipStr, port, useTLS, nil)
+		if err == nil {
+			var response string
+			response, _, _, _, err = directRequestH1(conn, variant, method, path, "")
+			conn.Close()
+			if err == nil {
+				variantStatus, _, variantLocation, variantLength := extractHTTPHeaders(response)
+				answer = strconv.Itoa(variantStatus)
+				if variantStatus != status || variantLocation != location || variantLength != contentLength {
+					verdict = "differs"
+				}
+			}
+		}
+		// a variant that couldn't be asked says nothing about routing, so it
+		// shows as - without changing the verdict
 
+		results = append(results, hostHeader(displayHost(variant))+"="+answer)
+	}
+
+	return verdict, strings.Join(results, " ")
+}
+
+func checkKeepAlive(hostCtx context.Context, host string, ipStr string, port string, useTLS bool, method string, path string) string {
+	conn, _, _, err := directConnect(hostCtx, host, ipStr, port, useTLS, nil)
+	if err != nil {
+		return "-"
+	}
+	defer conn.Close()
+
+	httpRequest := fmt.Sprintf("%s %s HTTP/1.1\r\nHost: %s\r\nUser-Agent: %s\r\nConnection: keep-alive\r\n\r\n", method, path, hostHeader(host), globalFlagUserAgent)
+	reader := bufio.NewReader(conn)
+
+	var warm time.Duration
+	for i := 0; i < 2; i++ {
+		requestStart := time.Now()
+		if _, err := conn.Write([]byte(httpRequest)); err != nil {
+			return "no"
+		}
+
+		resp, err := http.ReadResponse(reader, &http.Request{Method: method})
 		if err != nil {
-			continue
+			if i == 0 {
+				return "-"
+			}
+			return "no"
+		}
+		warm = time.Since(requestStart)
+
+		// the body has to be consumed before the next response can be read
+		_, err = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if err != nil || (i == 0 && resp.Close) {
+			return "no"
 		}
+	}
 
-		response := string(buffer[:n])
-		statusCode, server, location := extractHTTPHeaders(response)
+	return formatLatency(warm)
+}
 
-		if directFlagHideLocation != "" && location == directFlagHideLocation {
-			continue
+func directURL(useTLS bool, host string, port string, path string) *url.URL {
+	scheme, defaultPort := "http", "80"
+	if useTLS {
+		scheme, defaultPort = "https", "443"
+	}
+
+	hostPort := net.JoinHostPort(host, port)
+	if port == defaultPort {
+		hostPort = displayHost(host)
+	}
+
+	u, err := url.Parse(scheme + "://" + hostPort + path)
+	if err != nil {
+		return &url.URL{Scheme: scheme, Host: hostPort, Path: path}
+	}
+	return u
+}
+
+// followRedirects chases Location headers for up to --follow-redirects hops,
+// resolving and connecting to each new target. It returns the last URL
+// reached and its status, 0 when that request failed.
+func followRedirects(hostCtx context.Context, current *url.URL, method string, status int, location string) (final *url.URL, finalStatus int, hops int) {
+	final, finalStatus = current, status
+	for hops < directFlagRedirects && status/100 == 3 && location != "" {
+		next, err := final.Parse(location)
+		if err != nil || (next.Scheme != "http" && next.Scheme != "https") {
+			break
+		}
+		if status == http.StatusSeeOther && method != "HEAD" {
+			method = "GET"
+		}
+
+		hops++
+		final = next
+		status, location, err = directFetch(hostCtx, next, method)
+		if err != nil {
+			return final, 0, hops
+		}
+		finalStatus = status
+	}
+	return final, finalStatus, hops
+}
+
+// directFetch makes one HTTP/1.1 request to u and returns its status and Location.
+func directFetch(hostCtx context.Context, u *url.URL, method string) (int, string, error) {
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	ips, ok := directResolve[resolveKey(host, port)]
+	if !ok {
+		lookupCtx, cancel := context.WithTimeout(hostCtx, time.Duration(directFlagTimeoutDNS)*time.Second)
+		var err error
+		ips, err = lookupIP(lookupCtx, directFamily(), host)
+		cancel()
+		if err != nil {
+			return 0, "", withPhase("dns", err)
 		}
+		if len(ips) == 0 {
+			return 0, "", withPhase("dns", fmt.Errorf("no address for %s", host))
+		}
+		sortIPv4First(ips)
+	}
+
+	conn, _, _, err := directConnect(hostCtx, host, ips[0].String(), port, u.Scheme == "https", nil)
+	if err != nil {
+		return 0, "", err
+	}
+	defer conn.Close()
+
+	response, _, _, _, err := directRequestH1(conn, host, method, u.RequestURI(), "")
+	if err != nil {
+		return 0, "", err
+	}
+
+	status, _, location, _ := extractHTTPHeaders(response)
+	return status, location, nil
+}
+
+// directRequestH1 sends the request over conn and parses the answer with
+// net/http, so headers spread over several packets and chunked bodies are
+// read properly. The response comes back as its status line and headers.
+// A non-empty payload is sent as-is in place of the generated request.
+func directRequestH1(conn net.Conn, host string, method string, path string, payload string) (response string, body []byte, ttfb time.Duration, size int64, err error) {
+	protocol := "HTTP/1.1"
+	if directFlagHTTPVersion == "1.0" {
+		protocol = "HTTP/1.0"
+	}
 
-		hostWithPort := fmt.Sprintf("%s:%s", host, port)
-		formatted := fmt.Sprintf("%-15s  %-3d   %-16s    %s", ipStr, statusCode, server, hostWithPort)
+	header := http.Header{}
+	header.Set("User-Agent", globalFlagUserAgent)
+	header.Set("Connection", "close")
+	// hand-written requests don't get the transport's default, and without
+	// one servers won't compress for decodeBody to undo
+	header.Set("Accept-Encoding", "gzip, deflate")
+	host = hostHeader(host)
+	if override := applyRequestHeaders(header, directHeaders); override != "" {
+		host = override
+	}
+
+	// written by hand since Request.Write always says HTTP/1.1, and path is
+	// sent as given rather than parsed as a URL
+	var request bytes.Buffer
+	fmt.Fprintf(&request, "%s %s %s\r\nHost: %s\r\n", method, path, protocol, host)
+	header.Write(&request)
+	request.WriteString("\r\n")
+	if payload != "" {
+		request.Reset()
+		request.WriteString(payload)
+	}
 
-		ctx.ScanSuccess(formatted)
-		ctx.Log(formatted)
+	requestStart := time.Now()
+	if _, err := conn.Write(request.Bytes()); err != nil {
+		return "", nil, 0, 0, withPhase("write", err)
 	}
+
+	reader := bufio.NewReader(conn)
+	if _, err := reader.Peek(1); err != nil {
+		return "", nil, 0, 0, withPhase("read", err)
+	}
+	ttfb = time.Since(requestStart)
+
+	resp, err := http.ReadResponse(reader, &http.Request{Method: method})
+	if err != nil {
+		return "", nil, 0, 0, withPhase("read", err)
+	}
+	defer resp.Body.Close()
+
+	size, body = readDirectBody(resp)
+
+	return formatResponseHead(resp), body, ttfb, size, nil
+}
+
+// directPayload fills in a --payload template the same way proxy does, with
+// [ip] and [port] for the address being probed.
+func directPayload(host string, ipStr string, port string, method string, path string) string {
+	protocol := "HTTP/1.1"
+	if directFlagHTTPVersion == "1.0" {
+		protocol = "HTTP/1.0"
+	}
+
+	payload := insertPayloadHeaders(directFlagPayload, directHeaders)
+	payload = strings.NewReplacer(
+		"[method]", method,
+		"[path]", path,
+		"[protocol]", protocol,
+		"[host]", host,
+		"[ip]", ipStr,
+		"[port]", port,
+		"[ua]", globalFlagUserAgent,
+		"[crlf]", "\r\n",
+	).Replace(payload)
+
+	return expandPayloadFuncs(payload)
+}
+
+func resolveKey(host string, port string) string {
+	return strings.ToLower(host) + ":" + port
+}
+
+// parseResolve reads curl-style host:port:addr[,addr...] overrides.
+func parseResolve(entries []string) (map[string][]net.IP, error) {
+	overrides := make(map[string][]net.IP, len(entries))
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid resolve: %q (expected host:port:addr)", entry)
+		}
+
+		port, err := parsePort(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid resolve: %q: %w", entry, err)
+		}
+
+		key := resolveKey(toASCIIHost(parts[0]), strconv.Itoa(port))
+		for _, addr := range strings.Split(parts[2], ",") {
+			ip := net.ParseIP(strings.Trim(strings.TrimSpace(addr), "[]"))
+			if ip == nil {
+				return nil, fmt.Errorf("invalid resolve: %q: bad address %q", entry, addr)
+			}
+			overrides[key] = append(overrides[key], ip)
+		}
+	}
+	return overrides, nil
+}
+
+// parseStatusCodes reads a list of status codes and classes such as 3xx.
+func parseStatusCodes(spec string) ([]string, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var codes []string
+	for _, code := range strings.Split(spec, ",") {
+		code = strings.ToLower(strings.TrimSpace(code))
+		valid := len(code) == 3 && code[0] >= '1' && code[0] <= '5'
+		if valid && code[1:] != "xx" {
+			n, err := strconv.Atoi(code)
+			valid = err == nil && n >= 100
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid status code: %s", code)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func matchStatus(status int, codes []string) bool {
+	text := strconv.Itoa(status)
+	for _, code := range codes {
+		if code == text || (strings.HasSuffix(code, "xx") && len(text) == 3 && text[0] == code[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+func directFamily() string {
+	switch {
+	case directFlagIPv4:
+		return "ip4"
+	case directFlagIPv6:
+		return "ip6"
+	}
+	return "ip"
+}
+
+func sortIPv4First(ips []net.IP) {
+	slices.SortStableFunc(ips, func(a, b net.IP) int {
+		return cmpBool(a.To4() == nil, b.To4() == nil)
+	})
+}
+
+// cmpBool orders false before true.
+func cmpBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+func directMatching() bool {
+	return directFlagMatchString != "" || directMatch != nil
+}
+
+// directKeepBody reports whether the body itself is needed, not just its size.
+func directKeepBody() bool {
+	return directMatching() || directFlagTitle
+}
+
+// readDirectBody reads up to --max-body of the body when its size is wanted
+// or it has to be matched or searched for a title, keeping the bytes only
+// then, decompressed so content checks see the page itself. The size is what
+// came over the wire, -1 when the body isn't read.
+func readDirectBody(resp *http.Response) (int64, []byte) {
+	if !directFlagReadBody && !directKeepBody() {
+		return -1, nil
+	}
+
+	limited := io.LimitReader(resp.Body, directFlagMaxBody)
+	if !directKeepBody() {
+		size, _ := io.Copy(io.Discard, limited)
+		return size, nil
+	}
+
+	kept, _ := io.ReadAll(limited)
+	return int64(len(kept)), decodeBody(resp.Header.Get("Content-Encoding"), kept)
+}
+
+// decodeBody undoes gzip and deflate content encodings, returning as much
+// as decodes when the body was cut short by --max-body, and the body as it
+// is for any other encoding.
+func decodeBody(encoding string, body []byte) []byte {
+	var decoder io.Reader
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		decoder, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// meant to be zlib-wrapped, but some servers send raw deflate
+		decoder, err = zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			decoder, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return body
+	}
+	if err != nil {
+		return body
+	}
+
+	decoded, _ := io.ReadAll(io.LimitReader(decoder, directFlagMaxBody))
+	if len(decoded) == 0 {
+		return body
+	}
+	return decoded
+}
+
+func directBodyMatches(body []byte) bool {
+	if directFlagMatchString != "" && !bytes.Contains(body, []byte(directFlagMatchString)) {
+		return false
+	}
+	return directMatch == nil || directMatch.Match(body)
+}
+
+var titleRegex = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// pageTitle returns the collapsed, unescaped text of the first <title> in body.
+func pageTitle(body []byte) string {
+	match := titleRegex.FindSubmatch(body)
+	if match == nil {
+		return ""
+	}
+
+	title := strings.Join(strings.Fields(html.UnescapeString(string(match[1]))), " ")
+	if runes := []rune(title); len(runes) > 80 {
+		title = string(runes[:80]) + "..."
+	}
+	return title
+}
+
+// formatResponseHead renders the status line and headers of resp the way
+// they came over the wire, for the header parsers above.
+func formatResponseHead(resp *http.Response) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%s %s\r\n", resp.Proto, resp.Status)
+	resp.Header.Write(&builder)
+	builder.WriteString("\r\n")
+	return builder.String()
+}
+
+func directRequestH2(hostCtx context.Context, conn *tls.Conn, host string, method string, path string) (response string, body []byte, ttfb time.Duration, size int64, err error) {
+	transport := &http.Transport{
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return conn, nil
+		},
+		ForceAttemptHTTP2:  true,
+		DisableCompression: true,
+	}
+	defer transport.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(hostCtx, method, "https://"+hostHeader(host)+"/", nil)
+	if err != nil {
+		return "", nil, 0, 0, err
+	}
+	// sent as given, like the HTTP/1 request line
+	req.URL.Opaque = path
+	req.Header.Set("User-Agent", globalFlagUserAgent)
+	if hostHeader := applyRequestHeaders(req.Header, directHeaders); hostHeader != "" {
+		req.Host = hostHeader
+	}
+
+	requestStart := time.Now()
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return "", nil, 0, 0, withPhase("request", err)
+	}
+	defer resp.Body.Close()
+	ttfb = time.Since(requestStart)
+
+	size, body = readDirectBody(resp)
+
+	return formatResponseHead(resp), body, ttfb, size, nil
 }
 
 func scanDirectRun(cmd *cobra.Command, args []string) {
-	hosts, err := ReadFile(directFlagFilename)
+	var hosts []string
+	var err error
+
+	stream, streaming := streamInput(directFlagFilename)
+	if !streaming {
+		hosts, err = ReadFile(directFlagFilename)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	directPorts, err = resolvePorts(directFlagPort, cmd.Flags().Changed("port"), directFlagTopPorts, directFlagExcludePorts)
+	if err != nil {
+		fatal(err)
+	}
+
+	directTLSPorts, err = parsePorts(directFlagTLSPorts)
 	if err != nil {
 		fatal(err)
 	}
 
-	fmt.Printf("%-15s  %-3s  %-16s    %s\n", "IP Address", "Code", "Server", "Host")
-	fmt.Printf("%-15s  %-3s  %-16s    %s\n", "----------", "----", "------", "----")
+	directHTTPPorts, err = parsePorts(directFlagHTTPPorts)
+	if err != nil {
+		fatal(err)
+	}
+
+	directHeaders, err = parseRequestHeaders(directFlagHeaders)
+	if err != nil {
+		fatal(err)
+	}
+
+	if directFlagMatchRegex != "" {
+		directMatch, err = regexp.Compile(directFlagMatchRegex)
+		if err != nil {
+			fatal(fmt.Errorf("invalid match regex: %w", err))
+		}
+	}
+	if directFlagIPv4 && directFlagIPv6 {
+		fatal(fmt.Errorf("--ipv4 and --ipv6 can't be combined"))
+	}
 
-	qs := queuescanner.New(globalFlagThreads, scanDirect)
-	qs.SetOptions(hosts, directFlagOutput, globalFlagStatInterval)
-	qs.Start()
+	directShowCodes, err = parseStatusCodes(directFlagMatchCode)
+	if err != nil {
+		fatal(err)
+	}
+	directHideCodes, err = parseStatusCodes(directFlagFilterCode)
+	if err != nil {
+		fatal(err)
+	}
+
+	if directFlagPayload != "" && directFlagHTTPVersion == "2" {
+		fatal(fmt.Errorf("--payload is sent as HTTP/1 and can't be used with --http-version 2"))
+	}
+
+	if directFlagQUICProbe && len(globalFlagVia) > 0 {
+		fatal(fmt.Errorf("--quic-probe is sent over UDP and can't go through --via"))
+	}
+
+	if directKeepBody() && !cmd.Flags().Changed("method") {
+		directFlagMethod = "GET"
+	}
+
+	switch directFlagScheme {
+	case "http", "https", "auto":
+	default:
+		fatal(fmt.Errorf("invalid scheme: %s", directFlagScheme))
+	}
+
+	switch directFlagHTTPVersion {
+	case "1.0", "1.1", "2":
+	default:
+		fatal(fmt.Errorf("invalid http version: %s", directFlagHTTPVersion))
+	}
+
+	directResolve, err = parseResolve(directFlagResolve)
+	if err != nil {
+		fatal(err)
+	}
+
+	switch directFlagOutputFormat {
+	case "table", "json", "csv":
+		resultFormat = directFlagOutputFormat
+	default:
+		fatal(fmt.Errorf("invalid output format: %s (expected table, json or csv)", directFlagOutputFormat))
+	}
+
+	if directFlagSaveHeaders != "" {
+		if err := os.MkdirAll(directFlagSaveHeaders, 0755); err != nil {
+			fatal(err)
+		}
+	}
+
+	qs := newScanner(scanDirect)
+	qs.SetFormat(directFlagOutputFormat)
+	printHeader(qs,
+		fmt.Sprintf("%-15s  %-3s  %-16s  %-14s  %-7s  %-7s  %-7s  %-8s  %s", "IP Address", "Code", "Server", "Fingerprint", "Connect", "TLS", "TTFB", "Size", "Host"),
+		fmt.Sprintf("%-15s  %-3s  %-16s  %-14s  %-7s  %-7s  %-7s  %-8s  %s", "----------", "----", "------", "-----------", "-------", "---", "----", "----", "----"),
+	)
+	outputFile := scanOutputFile(directFlagOutput)
+	if directFlagSplitPorts {
+		outputFile = ""
+		if directFlagOutput != "" {
+			qs.AddWriter(queuescanner.NewSplitFileWriter(func(result *queuescanner.Result) string {
+				return suffixFilename(directFlagOutput, result.Port)
+			}, directFlagOutputFormat))
+		}
+	}
+
+	if streaming {
+		startStream(qs, stream, outputFile)
+	} else {
+		startScan(qs, hosts, outputFile)
+	}
+
+	if !directFlagSplitPorts {
+		writeSortedOutput(directFlagOutput)
+	}
+
+	if directFlagHAR != "" {
+		if err := writeHAR(directFlagHAR); err != nil {
+			fatal(err)
+		}
+	}
 }
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"slices"
+	"testing"
+)
+
+func compress(t *testing.T, newWriter func(io.Writer) io.WriteCloser, data string) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	w := newWriter(&b)
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	const page = "<html><title>hello</title></html>"
+
+	gzipped := compress(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, page)
+	zlibbed := compress(t, func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }, page)
+	deflated := compress(t, func(w io.Writer) io.WriteCloser {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	}, page)
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		want     string
+	}{
+		{"gzip", "gzip", gzipped, page},
+		{"x-gzip with spacing", " X-GZIP ", gzipped, page},
+		{"zlib deflate", "deflate", zlibbed, page},
+		{"raw deflate", "deflate", deflated, page},
+		{"truncated gzip", "gzip", gzipped[:len(gzipped)-8], page},
+		{"identity", "", []byte(page), page},
+		{"unknown encoding", "br", []byte("opaque"), "opaque"},
+		{"corrupt gzip", "gzip", []byte("not gzip"), "not gzip"},
+	}
+
+	for _, test := range tests {
+		if got := string(decodeBody(test.encoding, test.body)); got != test.want {
+			t.Errorf("%s: decodeBody = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"443", []string{"443"}},
+		{"80, 443", []string{"80", "443"}},
+		{"8000-8003", []string{"8000", "8001", "8002", "8003"}},
+		{"443,80-81,443,81", []string{"443", "80", "81"}},
+		{"65535", []string{"65535"}},
+	}
+
+	for _, test := range tests {
+		got, err := parsePorts(test.spec)
+		if err != nil {
+			t.Errorf("parsePorts(%q) error: %v", test.spec, err)
+			continue
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("parsePorts(%q) = %v, want %v", test.spec, got, test.want)
+		}
+	}
+
+	for _, spec := range []string{"", "0", "65536", "http", "90-80", "80-", "80,,443"} {
+		if got, err := parsePorts(spec); err == nil {
+			t.Errorf("parsePorts(%q) = %v, want an error", spec, got)
+		}
+	}
+}
@@ -1,9 +1,18 @@
 package cmd
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"net"
+	"os"
+	"os/signal"
 	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -21,34 +30,421 @@ var (
 	pingFlagFilename string
 	pingFlagTimeout  int
 	pingFlagOutput   string
-	pingFlagPort     int
+	pingFlagPort     string
+	pingFlagCount    int
+	pingFlagInterval time.Duration
+	pingFlagUDP      bool
+	pingFlagTTL      bool
+
+	pingFlagServiceDetect bool
+	pingFlagTopPorts      string
+	pingFlagExcludePorts  string
 )
 
+var (
+	pingPorts []string
+)
+
+type pingResult struct {
+	ip        string
+	reachable []string
+	dns       time.Duration
+	rtt       time.Duration
+	hops      int
+}
+
+type pingStat struct {
+	sent     int
+	received int
+	ip       string
+	min      time.Duration
+	max      time.Duration
+	total    time.Duration
+	dnsTotal time.Duration
+	hops     int
+}
+
 func init() {
 	rootCmd.AddCommand(pingCmd)
 
 	pingCmd.Flags().StringVarP(&pingFlagFilename, "filename", "f", "", "domain list filename")
 	pingCmd.Flags().IntVar(&pingFlagTimeout, "timeout", 2, "timeout in seconds")
 	pingCmd.Flags().StringVarP(&pingFlagOutput, "output", "o", "", "output result")
-	pingCmd.Flags().IntVar(&pingFlagPort, "port", 443, "port to use")
+	pingCmd.Flags().StringVar(&pingFlagPort, "port", "443", "port(s) to use - single (443) or multiple comma-separated (80,443,22)")
+	pingCmd.Flags().StringVar(&pingFlagTopPorts, "top-ports", "", "use a port preset - web, mail or all-common")
+	pingCmd.Flags().StringVar(&pingFlagExcludePorts, "exclude-ports", "", "comma-separated ports to skip")
+	pingCmd.Flags().IntVar(&pingFlagCount, "count", 1, "number of ping rounds per host, 0 to ping until interrupted")
+	pingCmd.Flags().DurationVar(&pingFlagInterval, "interval", time.Second, "delay between ping rounds")
+	pingCmd.Flags().BoolVar(&pingFlagUDP, "udp", false, "ping using UDP probes (DNS on 53, QUIC on 443, empty datagram otherwise)")
+	pingCmd.Flags().BoolVar(&pingFlagTTL, "ttl", false, "estimate hop count with a binary-search TTL probe (TCP, IPv4 only)")
+	pingCmd.Flags().BoolVar(&pingFlagServiceDetect, "service-detect", false, "probe open TCP ports and label the detected service (ssh, smtp, http, tls, rdp...)")
 }
 
-func pingHost(ctx *queuescanner.Ctx, host string) {
-	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(pingFlagPort)), time.Duration(pingFlagTimeout)*time.Second)
+func pingResolve(hostCtx context.Context, host string) (string, time.Duration, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return host, 0, nil
+	}
+
+	lookupCtx, cancel := context.WithTimeout(hostCtx, time.Duration(pingFlagTimeout)*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	ips, err := lookupIP(lookupCtx, "ip", host)
 	if err != nil {
-		return
+		return "", 0, err
 	}
-	defer conn.Close()
+	if len(ips) == 0 {
+		return "", 0, fmt.Errorf("no addresses for %s", host)
+	}
+
+	return ips[0].String(), time.Since(start), nil
+}
 
-	remoteAddr := conn.RemoteAddr()
-	ip, _, err := net.SplitHostPort(remoteAddr.String())
+func pingOnce(ctx *queuescanner.Ctx, host string) pingResult {
+	result := pingResult{reachable: make([]string, len(pingPorts))}
+	for i := range result.reachable {
+		result.reachable[i] = "-"
+	}
+
+	start := time.Now()
+
+	hostCtx, hostCancel := hostContext(ctx.Context())
+	defer hostCancel()
+
+	ip, dns, err := pingResolve(hostCtx, host)
 	if err != nil {
-		ip = remoteAddr.String()
+		logFailure("ping", host, host, "", withPhase("dns", err), start)
+		return result
+	}
+	result.dns = dns
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for i, port := range pingPorts {
+		wg.Add(1)
+		go func(i int, port string) {
+			defer wg.Done()
+
+			var state string
+			var rtt time.Duration
+			var err error
+			ctx.Pace(ip)
+			if pingFlagUDP {
+				state, rtt, err = pingUDPPort(hostCtx, ip, port)
+			} else {
+				state, rtt, err = pingTCPPort(hostCtx, ip, port)
+			}
+
+			result.reachable[i] = state
+			if state != "open" {
+				logFailure("ping", host, host, net.JoinHostPort(ip, port), err, start)
+				return
+			}
+
+			if pingFlagServiceDetect && !pingFlagUDP {
+				result.reachable[i] = detectService(hostCtx, ip, port, time.Until(hostDeadline(hostCtx, time.Duration(pingFlagTimeout)*time.Second)))
+			}
+
+			mu.Lock()
+			if result.ip == "" || rtt < result.rtt {
+				result.ip = ip
+				result.rtt = rtt
+			}
+			mu.Unlock()
+		}(i, port)
+	}
+	wg.Wait()
+
+	// hop counting needs direct sockets, a --via chain hides the real path
+	if pingFlagTTL && !pingFlagUDP && len(globalFlagVia) == 0 && result.ip != "" {
+		for i, state := range result.reachable {
+			if state != "-" {
+				result.hops = estimateHops(hostCtx, ip, pingPorts[i])
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+func dialWithTTL(hostCtx context.Context, ip string, port string, ttl int) bool {
+	dialer := &net.Dialer{
+		Timeout: time.Duration(pingFlagTimeout) * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			err := c.Control(func(fd uintptr) {
+				sockErr = setSocketTTL(fd, ttl)
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		},
 	}
 
-	formatted := fmt.Sprintf("%-16s %-20s", ip, host)
-	ctx.ScanSuccess(formatted)
+	conn, err := dialer.DialContext(hostCtx, "tcp4", net.JoinHostPort(ip, port))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+
+	return true
+}
+
+func estimateHops(hostCtx context.Context, ip string, port string) int {
+	if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+		return 0
+	}
+
+	// smallest TTL that still completes the handshake is the hop count
+	low, high := 1, 64
+	if !dialWithTTL(hostCtx, ip, port, high) {
+		return 0
+	}
+
+	for low < high {
+		mid := (low + high) / 2
+		if dialWithTTL(hostCtx, ip, port, mid) {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+
+	return low
+}
+
+func formatHops(hops int) string {
+	if hops == 0 {
+		return "-"
+	}
+	return strconv.Itoa(hops)
+}
+
+func pingTCPPort(hostCtx context.Context, ip string, port string) (string, time.Duration, error) {
+	dialCtx, cancel := context.WithTimeout(hostCtx, time.Duration(pingFlagTimeout)*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := dialContext(dialCtx, "tcp", net.JoinHostPort(ip, port))
+	if err != nil {
+		return "-", 0, withPhase("dial", err)
+	}
+	rtt := time.Since(start)
+	conn.Close()
+
+	return "open", rtt, nil
+}
+
+func pingUDPPort(hostCtx context.Context, ip string, port string) (string, time.Duration, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(hostCtx, "udp", net.JoinHostPort(ip, port))
+	if err != nil {
+		return "-", 0, withPhase("dial", err)
+	}
+	conn = throttleConn(conn)
+	defer conn.Close()
+
+	setHostDeadline(hostCtx, conn, time.Duration(pingFlagTimeout)*time.Second)
+
+	start := time.Now()
+	if _, err := conn.Write(udpProbe(port)); err != nil {
+		return "-", 0, withPhase("write", err)
+	}
+
+	buffer := make([]byte, 1500)
+	if _, err := conn.Read(buffer); err != nil {
+		// a connected UDP socket reports ICMP port unreachable as ECONNREFUSED
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return "closed", 0, withPhase("read", err)
+		}
+		return "filtered", 0, withPhase("read", err)
+	}
+
+	return "open", time.Since(start), nil
+}
+
+func udpProbe(port string) []byte {
+	switch port {
+	case "53":
+		// standard recursive query for example.com A
+		query := []byte{0x00, 0x00, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+		binary.BigEndian.PutUint16(query, uint16(rand.Intn(1<<16)))
+		query = append(query, 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0)
+		return append(query, 0x00, 0x01, 0x00, 0x01)
+	case "443":
+		// long header packet with a reserved version, answered with version negotiation by QUIC servers
+		packet := make([]byte, 1200)
+		packet[0] = 0xC0
+		binary.BigEndian.PutUint32(packet[1:], 0x1a2a3a4a)
+		packet[5] = 8
+		for i := 6; i < 14; i++ {
+			packet[i] = byte(rand.Intn(256))
+		}
+		packet[14] = 0
+		return packet
+	default:
+		return []byte{}
+	}
+}
+
+func pingHost(ctx *queuescanner.Ctx, host string) {
+	result := pingOnce(ctx, host)
+	if result.ip == "" {
+		return
+	}
+
+	if globalFlagUniqueIP && !ctx.ClaimUnique(result.ip) {
+		return
+	}
+
+	formatted := fmt.Sprintf("%-16s %-20s %-7s %-7s", result.ip, displayHost(host), formatLatency(result.dns), formatLatency(result.rtt))
+	if pingFlagTTL {
+		formatted += fmt.Sprintf(" %-5s", formatHops(result.hops))
+	}
+	if len(pingPorts) > 1 {
+		for _, state := range result.reachable {
+			formatted += fmt.Sprintf(" %-8s", state)
+		}
+	}
+
+	info := enrichIP(result.ip)
+	formatted += info.suffix()
+
+	success := &queuescanner.Result{
+		Host:    host,
+		IP:      result.ip,
+		Latency: result.rtt,
+		Extra:   info.merge(nil),
+		Line:    formatted,
+	}
+	ctx.ScanSuccess(success)
 	ctx.Log(formatted)
+	reportResult("ping", host, success)
+}
+
+func (stat *pingStat) record(result pingResult) {
+	stat.sent++
+	if result.ip == "" {
+		return
+	}
+
+	stat.received++
+	stat.ip = result.ip
+	stat.total += result.rtt
+	stat.dnsTotal += result.dns
+	stat.hops = result.hops
+	if stat.min == 0 || result.rtt < stat.min {
+		stat.min = result.rtt
+	}
+	if result.rtt > stat.max {
+		stat.max = result.rtt
+	}
+}
+
+func (stat *pingStat) sortKey() time.Duration {
+	if stat.received == 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return stat.total / time.Duration(stat.received)
+}
+
+func (stat *pingStat) format(host string) string {
+	loss := float64(stat.sent-stat.received) / float64(stat.sent) * 100
+
+	dns := "-"
+	rtt := "-"
+	if stat.received > 0 {
+		dns = formatLatency(stat.dnsTotal / time.Duration(stat.received))
+		avg := stat.total / time.Duration(stat.received)
+		rtt = fmt.Sprintf("%s/%s/%s", formatLatency(stat.min), formatLatency(avg), formatLatency(stat.max))
+	}
+
+	ip := stat.ip
+	if ip == "" {
+		ip = "-"
+	}
+
+	formatted := fmt.Sprintf("%-16s %-20s %4d %4d %5.1f%%  %-7s", ip, displayHost(host), stat.sent, stat.received, loss, dns)
+	if pingFlagTTL {
+		formatted += fmt.Sprintf(" %-5s", formatHops(stat.hops))
+	}
+
+	return formatted + " " + rtt
+}
+
+func pingContinuous(hosts []string) {
+	stats := make([]pingStat, len(hosts))
+
+	printStats := func() {
+		header := fmt.Sprintf("%-16s %-20s %4s %4s %6s  %-7s", "IP Address", "Host", "Sent", "Recv", "Loss", "DNS")
+		if pingFlagTTL {
+			header += fmt.Sprintf(" %-5s", "Hops")
+		}
+		fmt.Println(header + " Connect min/avg/max")
+		for i, host := range hosts {
+			fmt.Println(stats[i].format(host))
+		}
+		fmt.Println()
+	}
+
+	finish := func() {
+		if pingFlagOutput == "" {
+			return
+		}
+
+		var results sortableResults
+		for i, host := range hosts {
+			results.add(sortableResult{ip: stats[i].ip, host: host, latency: stats[i].sortKey(), line: stats[i].format(host)})
+		}
+
+		lines := results.lines()
+		if globalFlagSort != "" {
+			lines = results.sortedLines(globalFlagSort)
+		}
+
+		if err := appendToFile(pingFlagOutput, lines...); err != nil {
+			fatal(err)
+		}
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	for round := 1; pingFlagCount == 0 || round <= pingFlagCount; round++ {
+		sem := make(chan struct{}, globalFlagThreads)
+		var wg sync.WaitGroup
+
+		for i, host := range hosts {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, host string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				stats[i].record(pingOnce(nil, host))
+			}(i, host)
+		}
+		wg.Wait()
+
+		fmt.Printf("round %d\n", round)
+		printStats()
+
+		if pingFlagCount != 0 && round == pingFlagCount {
+			break
+		}
+
+		select {
+		case <-sigChan:
+			finish()
+			return
+		case <-time.After(pingFlagInterval):
+		}
+	}
+
+	finish()
 }
 
 func pingRun(cmd *cobra.Command, args []string) {
@@ -57,10 +453,40 @@ func pingRun(cmd *cobra.Command, args []string) {
 		fatal(err)
 	}
 
-	fmt.Printf("%-16s %-20s\n", "IP Address", "Host")
-	fmt.Printf("%-16s %-20s\n", "----------", "----")
+	pingPorts, err = resolvePorts(pingFlagPort, cmd.Flags().Changed("port"), pingFlagTopPorts, pingFlagExcludePorts)
+	if err != nil {
+		fatal(err)
+	}
+
+	if pingFlagCount < 0 {
+		fatal(fmt.Errorf("count must not be negative: %d", pingFlagCount))
+	}
+
+	if pingFlagUDP && len(globalFlagVia) > 0 {
+		fatal(fmt.Errorf("--udp probes can't go through --via"))
+	}
+
+	if pingFlagCount != 1 {
+		pingContinuous(hosts)
+		return
+	}
+
+	header := fmt.Sprintf("%-16s %-20s %-7s %-7s", "IP Address", "Host", "DNS", "Connect")
+	separator := fmt.Sprintf("%-16s %-20s %-7s %-7s", "----------", "----", "---", "-------")
+	if pingFlagTTL {
+		header += fmt.Sprintf(" %-5s", "Hops")
+		separator += fmt.Sprintf(" %-5s", "----")
+	}
+	if len(pingPorts) > 1 {
+		for _, port := range pingPorts {
+			header += fmt.Sprintf(" %-8s", port)
+			separator += fmt.Sprintf(" %-8s", "----")
+		}
+	}
+
+	qs := newScanner(pingHost)
+	printHeader(qs, header, separator)
+	startScan(qs, hosts, scanOutputFile(pingFlagOutput))
 
-	qs := queuescanner.New(globalFlagThreads, pingHost)
-	qs.SetOptions(hosts, pingFlagOutput, globalFlagStatInterval)
-	qs.Start()
+	writeSortedOutput(pingFlagOutput)
 }
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
+	"iter"
 	"net"
 	"strconv"
 	"strings"
@@ -24,116 +26,324 @@ var (
 	proxyFlagProxyCIDR         string
 	proxyFlagProxyHost         string
 	proxyFlagProxyHostFilename string
-	proxyFlagProxyPort         int
+	proxyFlagProxyPort         string
 	proxyFlagBug               string
 	proxyFlagMethod            string
-	proxyFlagTarget            string
+	proxyFlagTargets           []string
+	proxyFlagTargetFilename    string
 	proxyFlagPath              string
 	proxyFlagProtocol          string
-	proxyFlagPayload           string
+	proxyFlagPayloads          []string
+	proxyFlagHeaders           []string
+	proxyHeaders               []requestHeader
 	proxyFlagTimeout           int
 	proxyFlagOutput            string
+	proxyFlagSocks             bool
+	proxyFlagTryAll            bool
 )
 
+type proxyResponse struct {
+	lines   []string
+	latency time.Duration
+}
+
 func init() {
 	rootCmd.AddCommand(proxyCmd)
 
 	proxyCmd.Flags().StringVarP(&proxyFlagProxyCIDR, "cidr", "c", "", "cidr proxy to scan e.g. 104.16.0.0/24")
 	proxyCmd.Flags().StringVar(&proxyFlagProxyHost, "proxy", "", "proxy without port")
 	proxyCmd.Flags().StringVarP(&proxyFlagProxyHostFilename, "filename", "f", "", "proxy filename without port")
-	proxyCmd.Flags().IntVarP(&proxyFlagProxyPort, "port", "p", 80, "proxy port")
+	proxyCmd.Flags().StringVarP(&proxyFlagProxyPort, "port", "p", "80", "proxy port(s) - single (80) or multiple comma-separated (80,8080,3128)")
 	proxyCmd.Flags().StringVarP(&proxyFlagBug, "bug", "B", "", "bug to use when proxy is ip instead of domain")
 	proxyCmd.Flags().StringVarP(&proxyFlagMethod, "method", "M", "GET", "request method")
-	proxyCmd.Flags().StringVar(&proxyFlagTarget, "target", "", "target server (response must be 101)")
+	proxyCmd.Flags().StringArrayVar(&proxyFlagTargets, "target", nil, "target server (response must be 101), repeat to probe several")
+	proxyCmd.Flags().StringVar(&proxyFlagTargetFilename, "target-filename", "", "target server list filename")
 	proxyCmd.Flags().StringVar(&proxyFlagPath, "path", "/", "request path")
 	proxyCmd.Flags().StringVar(&proxyFlagProtocol, "protocol", "HTTP/1.1", "request protocol")
-	proxyCmd.Flags().StringVar(&proxyFlagPayload, "payload", "[method] [path] [protocol][crlf]Host: [host][crlf]Upgrade: websocket[crlf][crlf]", "request payload for sending throught proxy")
+	proxyCmd.Flags().StringArrayVar(&proxyFlagPayloads, "payload", []string{"[method] [path] [protocol][crlf]Host: [host][crlf]Upgrade: websocket[crlf][crlf]"}, "request payload for sending throught proxy, repeat to try several in order; supports [ua], [urlencode:...], [b64:...] and [hexlify:...]")
 	proxyCmd.Flags().IntVar(&proxyFlagTimeout, "timeout", 3, "handshake timeout")
 	proxyCmd.Flags().StringVarP(&proxyFlagOutput, "output", "o", "", "output result")
+	proxyCmd.Flags().StringArrayVarP(&proxyFlagHeaders, "header", "H", nil, "extra header added to the payload's header block, repeatable e.g. -H \"X-Online-Host: [host]\"")
+	proxyCmd.Flags().BoolVar(&proxyFlagTryAll, "try-all", false, "try every payload instead of stopping at the first success")
+	proxyCmd.Flags().BoolVar(&proxyFlagSocks, "socks", false, "probe for SOCKS5/SOCKS4 when the payload gets no response")
 }
 
-func scanProxy(ctx *queuescanner.Ctx, host string) {
+func scanProxy(ctx *queuescanner.Ctx, address string) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return
+	}
+
+	hostCtx, hostCancel := hostContext(ctx.Context())
+	defer hostCancel()
 
+	responded := false
+	var matrix []string
+	passCount := 0
+	var bestLatency time.Duration
+
+	// a retry runs every target again, so only ask for one when nothing
+	// was reported yet
+	reported := false
+	var failure error
+	defer func() {
+		if !reported && failure != nil {
+			retryOnTransient(ctx, address, failure)
+		}
+	}()
+
+	for _, target := range proxyFlagTargets {
+		passed, targetResponded, latency, err := scanProxyTarget(ctx, hostCtx, host, address, target)
+		if err != nil && failure == nil {
+			failure = err
+		}
+
+		if targetResponded {
+			responded = true
+		}
+
+		if passed {
+			passCount++
+			matrix = append(matrix, target+"=pass")
+			if bestLatency == 0 || latency < bestLatency {
+				bestLatency = latency
+			}
+		} else {
+			matrix = append(matrix, target+"=fail")
+		}
+	}
+
+	// a passing target is reported on its own, or in the matrix below
+	reported = passCount > 0
+
+	if len(proxyFlagTargets) > 1 && passCount > 0 {
+		resultString := fmt.Sprintf("%-32s %-7s %s", address, formatLatency(bestLatency), strings.Join(matrix, " "))
+		proxySuccess(ctx, address, "", 0, bestLatency, resultString)
+	}
+
+	if responded || !proxyFlagSocks {
+		return
+	}
+
+	protocol, latency := probeSocks(hostCtx, address)
+	if protocol == "" {
+		return
+	}
+
+	resultString := fmt.Sprintf("%-32s %-7s %s", address, formatLatency(latency), protocol)
+	version, _, _ := strings.Cut(protocol, " ")
+	proxySuccess(ctx, address, version, 0, latency, resultString)
+	reported = true
+}
+
+// proxySuccess records a working proxy, counted under tag in the summary
+// unless tag is empty.
+func proxySuccess(ctx *queuescanner.Ctx, address string, tag string, status int, latency time.Duration, resultString string) {
+	host, port, _ := net.SplitHostPort(address)
+
+	info := enrichIP(host)
+	resultString += info.suffix()
+
+	result := &queuescanner.Result{
+		Host:    host,
+		Port:    port,
+		Status:  status,
+		Latency: latency,
+		Extra:   info.merge(nil),
+		Line:    resultString,
+	}
+	if tag != "" {
+		ctx.ScanSuccessTagged(tag, result)
+	} else {
+		ctx.ScanSuccess(result)
+	}
+	ctx.Log(colorStatus(resultString, status))
+	reportResult("proxy", address, result)
+}
+
+func scanProxyTarget(ctx *queuescanner.Ctx, hostCtx context.Context, host string, address string, target string) (passed bool, responded bool, latency time.Duration, err error) {
 	bug := proxyFlagBug
 	if bug == "" {
 		if ipRegex.MatchString(host) {
-			bug = proxyFlagTarget
+			bug = target
 		} else {
 			bug = host
 		}
 	}
 
 	if proxyFlagPath == "/" {
-		bug = proxyFlagTarget
+		bug = target
+	}
+
+	for i, payload := range proxyFlagPayloads {
+		ctx.Pace(host)
+		requestStart := time.Now()
+		responseLines, requestLatency, err := proxyRequest(hostCtx, address, bug, target, payload)
+		if err != nil {
+			logFailure("proxy", address, host, address, err, requestStart)
+			return passed, responded, latency, err
+		}
+
+		if len(responseLines) == 0 {
+			logFailureClass("proxy", address, host, address, "response", "no-response", nil, requestStart)
+			continue
+		}
+		responded = true
+
+		if strings.Contains(responseLines[0], " 302 ") {
+			continue
+		}
+
+		if !passed || requestLatency < latency {
+			latency = requestLatency
+		}
+		passed = true
+
+		resultString := fmt.Sprintf("%-32s %-7s %s", address, formatLatency(requestLatency), strings.Join(responseLines, " -- "))
+		if len(proxyFlagPayloads) > 1 {
+			resultString += fmt.Sprintf(" -- payload #%d", i+1)
+		}
+
+		if len(proxyFlagTargets) > 1 {
+			ctx.Log(colorStatus(resultString+" -- target "+target, statusFromLine(responseLines[0])))
+		} else {
+			status := statusFromLine(responseLines[0])
+			proxySuccess(ctx, address, "status "+strconv.Itoa(status), status, requestLatency, resultString)
+		}
+
+		if !proxyFlagTryAll {
+			break
+		}
 	}
 
-	address := net.JoinHostPort(host, strconv.Itoa(proxyFlagProxyPort))
+	return passed, responded, latency, nil
+}
+
+func proxyRequest(hostCtx context.Context, address string, bug string, target string, payload string) ([]string, time.Duration, error) {
+	start := time.Now()
 
-	conn, err := net.DialTimeout("tcp", address, 3*time.Second)
+	dialCtx, dialCancel := fallbackContext(hostCtx, 3*time.Second)
+	defer dialCancel()
+
+	conn, err := dialContext(dialCtx, "tcp", address)
 	if err != nil {
-		return
+		return nil, 0, withPhase("dial", err)
 	}
 	defer conn.Close()
 
-	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	timeoutCtx, cancel := fallbackContext(hostCtx, 10*time.Second)
 	defer cancel()
 
-	resultCh := make(chan bool)
+	resultCh := make(chan proxyResponse, 1)
 
 	go func() {
-		payload := getScanProxyPayloadDecoded(bug)
-		payload = strings.ReplaceAll(payload, "[host]", proxyFlagTarget)
+		payload := insertPayloadHeaders(getScanProxyPayloadDecoded(payload, bug), proxyHeaders)
+		payload = strings.ReplaceAll(payload, "[host]", target)
 		payload = strings.ReplaceAll(payload, "[crlf]", "\r\n")
+		payload = expandPayloadFuncs(payload)
 
-		_, err = conn.Write([]byte(payload))
+		_, err := conn.Write([]byte(payload))
 		if err != nil {
-			resultCh <- false
+			resultCh <- proxyResponse{}
 			return
 		}
 
 		scanner := bufio.NewScanner(conn)
 		isPrefix := true
 		responseLines := []string{}
+		var latency time.Duration
 
 		for scanner.Scan() {
 			line := scanner.Text()
 			if line == "" {
 				break
 			}
-			if isPrefix || strings.HasPrefix(line, "Location") || strings.HasPrefix(line, "Server") {
+			if isPrefix {
+				latency = time.Since(start)
+			}
+			if isPrefix || isReportedHeader(line) {
 				isPrefix = false
 				responseLines = append(responseLines, line)
 			}
 		}
 
-		if len(responseLines) == 0 {
-			resultCh <- false
-			return
+		resultCh <- proxyResponse{lines: responseLines, latency: latency}
+	}()
+
+	select {
+	case response := <-resultCh:
+		return response.lines, response.latency, nil
+	case <-timeoutCtx.Done():
+		return nil, 0, nil
+	}
+}
+
+func probeSocks(hostCtx context.Context, address string) (string, time.Duration) {
+	if reply, latency := socksExchange(hostCtx, address, []byte{0x05, 0x02, 0x00, 0x02}); len(reply) == 2 && reply[0] == 0x05 {
+		switch reply[1] {
+		case 0x00:
+			return "SOCKS5 -- no auth", latency
+		case 0x02:
+			return "SOCKS5 -- auth required", latency
+		default:
+			return "SOCKS5 -- no acceptable method", latency
 		}
+	}
 
-		if strings.Contains(responseLines[0], " 302 ") {
-			resultCh <- true
-			return
+	target := proxyFlagTargets[0]
+	if target == "" {
+		target = "example.com"
+	}
+
+	// SOCKS4a CONNECT to target:80 (ip 0.0.0.1 means the hostname follows the user id)
+	request := []byte{0x04, 0x01, 0x00, 0x50, 0x00, 0x00, 0x00, 0x01, 0x00}
+	request = append(request, target...)
+	request = append(request, 0x00)
+
+	if reply, latency := socksExchange(hostCtx, address, request); len(reply) >= 2 && reply[0] == 0x00 {
+		switch reply[1] {
+		case 0x5A:
+			return "SOCKS4 -- request granted", latency
+		case 0x5B, 0x5C, 0x5D:
+			return "SOCKS4 -- request rejected", latency
 		}
+	}
 
-		resultString := fmt.Sprintf("%-32s %s", address, strings.Join(responseLines, " -- "))
-		ctx.ScanSuccess(resultString)
-		ctx.Log(resultString)
+	return "", 0
+}
 
-		resultCh <- true
-	}()
+func socksExchange(hostCtx context.Context, address string, request []byte) ([]byte, time.Duration) {
+	start := time.Now()
 
-	select {
-	case <-resultCh:
-	case <-timeoutCtx.Done():
+	dialCtx, dialCancel := fallbackContext(hostCtx, 3*time.Second)
+	defer dialCancel()
+
+	conn, err := dialContext(dialCtx, "tcp", address)
+	if err != nil {
+		return nil, 0
+	}
+	defer conn.Close()
+
+	setHostDeadline(hostCtx, conn, time.Duration(proxyFlagTimeout)*time.Second)
+
+	if _, err := conn.Write(request); err != nil {
+		return nil, 0
 	}
+
+	reply := make([]byte, 8)
+	n, err := io.ReadAtLeast(conn, reply, 2)
+	if err != nil {
+		return nil, 0
+	}
+
+	return reply[:n], time.Since(start)
 }
 
-func getScanProxyPayloadDecoded(bug ...string) string {
-	payload := proxyFlagPayload
+func getScanProxyPayloadDecoded(payload string, bug ...string) string {
 	payload = strings.ReplaceAll(payload, "[method]", strings.ToUpper(proxyFlagMethod))
 	payload = strings.ReplaceAll(payload, "[path]", proxyFlagPath)
 	payload = strings.ReplaceAll(payload, "[protocol]", proxyFlagProtocol)
+	payload = strings.ReplaceAll(payload, "[ua]", globalFlagUserAgent)
 	if len(bug) > 0 {
 		payload = strings.ReplaceAll(payload, "[bug]", bug[0])
 	}
@@ -155,16 +365,67 @@ func runScanProxy(cmd *cobra.Command, args []string) {
 		proxyHosts = append(proxyHosts, lines...)
 	}
 
+	if proxyFlagTargetFilename != "" {
+		lines, err := ReadFile(proxyFlagTargetFilename)
+		if err != nil {
+			fatal(err)
+		}
+		proxyFlagTargets = append(proxyFlagTargets, lines...)
+	}
+
+	ports, err := parsePorts(proxyFlagProxyPort)
+	if err != nil {
+		fatal(err)
+	}
+
+	proxyHeaders, err = parseRequestHeaders(proxyFlagHeaders)
+	if err != nil {
+		fatal(err)
+	}
+
+	var tasks []string
+	for _, host := range proxyHosts {
+		for _, port := range ports {
+			tasks = append(tasks, net.JoinHostPort(host, port))
+		}
+	}
+
+	var cidrTasks iter.Seq[string]
+	cidrTotal := 0
 	if proxyFlagProxyCIDR != "" {
-		cidrHosts, err := IPsFromCIDR(proxyFlagProxyCIDR)
+		cidrHosts, total, err := IPsFromCIDR(proxyFlagProxyCIDR)
 		if err != nil {
 			fatal(err)
 		}
-		proxyHosts = append(proxyHosts, cidrHosts...)
+		if total >= 0 {
+			cidrTotal = total * len(ports)
+		} else {
+			cidrTotal = -1
+		}
+		cidrTasks = func(yield func(string) bool) {
+			for host := range cidrHosts {
+				for _, port := range ports {
+					if !yield(net.JoinHostPort(host, port)) {
+						return
+					}
+				}
+			}
+		}
+	}
+
+	for i, target := range proxyFlagTargets {
+		proxyFlagTargets[i] = toASCIIHost(target)
+	}
+
+	if len(proxyFlagTargets) == 0 {
+		proxyFlagTargets = []string{""}
+	}
+
+	qs := newScanner(scanProxy)
+	for _, payload := range proxyFlagPayloads {
+		fmt.Printf("%s\n\n", getScanProxyPayloadDecoded(payload))
 	}
+	startScanSource(qs, tasks, cidrTasks, cidrTotal, "cidr "+proxyFlagProxyCIDR, scanOutputFile(proxyFlagOutput))
 
-	qs := queuescanner.New(globalFlagThreads, scanProxy)
-	fmt.Printf("%s\n\n", getScanProxyPayloadDecoded())
-	qs.SetOptions(proxyHosts, proxyFlagOutput, globalFlagStatInterval)
-	qs.Start()
+	writeSortedOutput(proxyFlagOutput)
 }
@@ -36,8 +36,19 @@ func init() {
 }
 
 func scanSNI(ctx *queuescanner.Ctx, host string) {
-	conn, err := net.DialTimeout("tcp", host+":443", 3*time.Second)
+	start := time.Now()
+
+	hostCtx, hostCancel := hostContext(ctx.Context())
+	defer hostCancel()
+
+	dialCtx, dialCancel := fallbackContext(hostCtx, 3*time.Second)
+	defer dialCancel()
+
+	ctx.Pace(host)
+	conn, err := dialContext(dialCtx, "tcp", host+":443")
 	if err != nil {
+		logFailure("sni", host, host, "", withPhase("dial", err), start)
+		retryOnTransient(ctx, host, err)
 		return
 	}
 	defer conn.Close()
@@ -54,17 +65,33 @@ func scanSNI(ctx *queuescanner.Ctx, host string) {
 	})
 	defer tlsConn.Close()
 
-	handshakeCtx, cancel := context.WithTimeout(context.Background(), time.Duration(sniFlagTimeout)*time.Second)
+	handshakeCtx, cancel := context.WithTimeout(hostCtx, time.Duration(sniFlagTimeout)*time.Second)
 	defer cancel()
 
 	err = tlsConn.HandshakeContext(handshakeCtx)
 	if err != nil {
+		logFailure("sni", host, host, remoteAddr.String(), withPhase("tls", err), start)
+		retryOnTransient(ctx, host, err)
 		return
 	}
 
-	formatted := fmt.Sprintf("%-16s %-20s", ip, host)
-	ctx.ScanSuccess(formatted)
-	ctx.Log(formatted)
+	if globalFlagUniqueIP && !ctx.ClaimUnique(ip) {
+		return
+	}
+
+	info := enrichIP(ip)
+	formatted := fmt.Sprintf("%-16s %-20s", ip, displayHost(host)) + info.suffix()
+	result := &queuescanner.Result{
+		Host:    host,
+		IP:      ip,
+		Port:    "443",
+		Latency: time.Since(start),
+		Extra:   info.merge(nil),
+		Line:    formatted,
+	}
+	ctx.ScanSuccess(result)
+	ctx.Log(colorTLS(formatted))
+	reportResult("sni", host, result)
 }
 
 func runScanSNI(cmd *cobra.Command, args []string) {
@@ -85,10 +112,12 @@ func runScanSNI(cmd *cobra.Command, args []string) {
 		domains = append(domains, domain)
 	}
 
-	fmt.Printf("%-16s %-20s\n", "IP Address", "SNI")
-	fmt.Printf("%-16s %-20s\n", "----------", "----")
+	qs := newScanner(scanSNI)
+	printHeader(qs,
+		fmt.Sprintf("%-16s %-20s", "IP Address", "SNI"),
+		fmt.Sprintf("%-16s %-20s", "----------", "----"),
+	)
+	startScan(qs, domains, scanOutputFile(sniFlagOutput))
 
-	qs := queuescanner.New(globalFlagThreads, scanSNI)
-	qs.SetOptions(domains, sniFlagOutput, globalFlagStatInterval)
-	qs.Start()
+	writeSortedOutput(sniFlagOutput)
 }
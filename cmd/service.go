@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"time"
+)
+
+var rdpConnectionRequest = []byte{
+	0x03, 0x00, 0x00, 0x13, 0x0e, 0xe0, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x01, 0x00, 0x08, 0x00, 0x03,
+	0x00, 0x00, 0x00,
+}
+
+var httpProbe = []byte("HEAD / HTTP/1.0\r\n\r\n")
+
+func serviceExchange(hostCtx context.Context, address string, timeout time.Duration, request []byte) []byte {
+	dialCtx, cancel := context.WithTimeout(hostCtx, timeout)
+	defer cancel()
+
+	conn, err := dialContext(dialCtx, "tcp", address)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	setHostDeadline(hostCtx, conn, timeout)
+
+	if len(request) > 0 {
+		if _, err := conn.Write(request); err != nil {
+			return nil
+		}
+	}
+
+	buffer := make([]byte, 512)
+	n, _ := conn.Read(buffer)
+
+	return buffer[:n]
+}
+
+func classifyBanner(banner []byte) string {
+	text := string(banner)
+
+	switch {
+	case strings.HasPrefix(text, "SSH-"):
+		return "ssh"
+	case strings.HasPrefix(text, "220") && strings.Contains(strings.ToUpper(text), "FTP"):
+		return "ftp"
+	case strings.HasPrefix(text, "220"):
+		return "smtp"
+	case strings.HasPrefix(text, "+OK"):
+		return "pop3"
+	case strings.HasPrefix(text, "* OK"):
+		return "imap"
+	case strings.HasPrefix(text, "HTTP/"):
+		return "http"
+	}
+
+	return ""
+}
+
+func detectTLSService(hostCtx context.Context, address string, timeout time.Duration) string {
+	dialCtx, cancel := context.WithTimeout(hostCtx, timeout)
+	defer cancel()
+
+	conn, err := dialContext(dialCtx, "tcp", address)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+
+	setHostDeadline(hostCtx, conn, timeout)
+
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+	if err := tlsConn.Handshake(); err != nil {
+		return ""
+	}
+
+	if _, err := tlsConn.Write(httpProbe); err != nil {
+		return "tls"
+	}
+
+	buffer := make([]byte, 16)
+	n, _ := tlsConn.Read(buffer)
+	if bytes.HasPrefix(buffer[:n], []byte("HTTP/")) {
+		return "https"
+	}
+
+	return "tls"
+}
+
+func detectService(hostCtx context.Context, ip string, port string, timeout time.Duration) string {
+	address := net.JoinHostPort(ip, port)
+
+	// services that speak first identify themselves in the banner
+	if service := classifyBanner(serviceExchange(hostCtx, address, timeout, nil)); service != "" {
+		return service
+	}
+
+	if service := detectTLSService(hostCtx, address, timeout); service != "" {
+		return service
+	}
+
+	if response := serviceExchange(hostCtx, address, timeout, httpProbe); bytes.HasPrefix(response, []byte("HTTP/")) {
+		return "http"
+	}
+
+	if response := serviceExchange(hostCtx, address, timeout, rdpConnectionRequest); len(response) >= 2 && response[0] == 0x03 && response[1] == 0x00 {
+		return "rdp"
+	}
+
+	return "open"
+}
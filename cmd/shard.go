@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// shardIndex is zero-based; shardCount of 0 or 1 means no sharding.
+var shardIndex, shardCount int
+
+// setupShard parses --shard i/n.
+func setupShard() error {
+	if globalFlagShard == "" {
+		return nil
+	}
+
+	i, n, ok := strings.Cut(globalFlagShard, "/")
+	index, err1 := strconv.Atoi(strings.TrimSpace(i))
+	count, err2 := strconv.Atoi(strings.TrimSpace(n))
+	if !ok || err1 != nil || err2 != nil || count < 1 || index < 1 || index > count {
+		return fmt.Errorf("invalid shard: %s (expected i/n with 1 <= i <= n)", globalFlagShard)
+	}
+
+	shardIndex, shardCount = index-1, count
+	return nil
+}
+
+// inShard reports whether the task at position index of the full input
+// belongs to this shard. Every shard sees the same input, so taking every
+// n-th task splits it without overlap.
+func inShard(index int) bool {
+	return shardCount <= 1 || index%shardCount == shardIndex
+}
+
+func shardTasks(tasks []string) []string {
+	if shardCount <= 1 {
+		return tasks
+	}
+
+	var mine []string
+	for i, task := range tasks {
+		if inShard(i) {
+			mine = append(mine, task)
+		}
+	}
+	return mine
+}
+
+// shardSource shards source as if its tasks followed offset others.
+func shardSource(offset int, source iter.Seq[string]) iter.Seq[string] {
+	if shardCount <= 1 {
+		return source
+	}
+
+	return func(yield func(string) bool) {
+		index := offset
+		for task := range source {
+			if inShard(index) && !yield(task) {
+				return
+			}
+			index++
+		}
+	}
+}
+
+// shardTotal counts how many of total tasks after offset fall in this shard.
+func shardTotal(offset int, total int) int {
+	if shardCount <= 1 || total < 0 {
+		return total
+	}
+
+	first := (shardIndex - offset%shardCount + shardCount) % shardCount
+	if first >= total {
+		return 0
+	}
+	return (total-first-1)/shardCount + 1
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func withShard(t *testing.T, index int, count int) {
+	t.Helper()
+
+	oldIndex, oldCount := shardIndex, shardCount
+	shardIndex, shardCount = index, count
+	t.Cleanup(func() { shardIndex, shardCount = oldIndex, oldCount })
+}
+
+func TestShardTasks(t *testing.T) {
+	var tasks []string
+	for i := 0; i < 10; i++ {
+		tasks = append(tasks, "host"+strconv.Itoa(i))
+	}
+
+	for _, count := range []int{1, 2, 3, 4, 10, 11} {
+		seen := make(map[string]int)
+		for index := 0; index < count; index++ {
+			withShard(t, index, count)
+			mine := shardTasks(tasks)
+
+			// every n-th task from the shard's own position, in input order
+			var want []string
+			for i := index; i < len(tasks); i += count {
+				want = append(want, tasks[i])
+			}
+			if !slices.Equal(mine, want) {
+				t.Errorf("shard %d/%d = %v, want %v", index+1, count, mine, want)
+			}
+			for _, task := range mine {
+				seen[task]++
+			}
+		}
+
+		for _, task := range tasks {
+			if seen[task] != 1 {
+				t.Errorf("%d shards: %s in %d shards, want exactly one", count, task, seen[task])
+			}
+		}
+	}
+}
+
+// shardSource and shardTotal continue the numbering after tasks, so a CIDR
+// source behind a file of hosts is split the same way as one long list.
+func TestShardSourceTotal(t *testing.T) {
+	for _, count := range []int{2, 3, 5} {
+		for index := 0; index < count; index++ {
+			withShard(t, index, count)
+
+			for offset := 0; offset < 7; offset++ {
+				for total := 0; total < 12; total++ {
+					source := func(yield func(string) bool) {
+						for i := 0; i < total; i++ {
+							if !yield(strconv.Itoa(i)) {
+								return
+							}
+						}
+					}
+
+					got := 0
+					for task := range shardSource(offset, source) {
+						i, _ := strconv.Atoi(task)
+						if (offset+i)%count != index {
+							t.Errorf("shard %d/%d offset %d: got task %s", index+1, count, offset, task)
+						}
+						got++
+					}
+					if want := shardTotal(offset, total); got != want {
+						t.Errorf("shard %d/%d offset %d total %d: shardSource yields %d, shardTotal says %d", index+1, count, offset, total, got, want)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ayanrajpoot10/bugscanx-go/pkg/resultstore"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Print aggregate statistics from the result store.",
+	Run:   runStats,
+}
+
+var (
+	statsFlagCommand string
+	statsFlagTag     string
+	statsFlagRuns    int
+	statsFlagDays    int
+)
+
+type storeRunStats struct {
+	run       string
+	command   string
+	started   time.Time
+	tags      []string
+	results   int
+	successes int
+	hosts     map[string]bool
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().StringVar(&statsFlagCommand, "command", "", "only include results from this command e.g. direct or monitor")
+	statsCmd.Flags().StringVar(&statsFlagTag, "filter-tag", "", "only include results recorded with this tag")
+	statsCmd.Flags().IntVar(&statsFlagRuns, "runs", 10, "number of most recent runs to list (0 for all)")
+	statsCmd.Flags().IntVar(&statsFlagDays, "days", 14, "number of days in the working hosts trend (0 for all)")
+}
+
+func printCounts(title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	fmt.Printf("\n%s\n", title)
+	for _, key := range keys {
+		fmt.Printf("  %-24s %d\n", key, counts[key])
+	}
+}
+
+func runStats(cmd *cobra.Command, args []string) {
+	store, err := openStore()
+	if err != nil {
+		fatal(err)
+	}
+
+	runs := make(map[string]*storeRunStats)
+	byCDN := make(map[string]int)
+	byCountry := make(map[string]int)
+	byDay := make(map[string]map[string]bool)
+	total, successes := 0, 0
+
+	err = store.Each(func(record resultstore.Record) error {
+		if statsFlagCommand != "" && record.Command != statsFlagCommand {
+			return nil
+		}
+		if statsFlagTag != "" && !record.HasTag(statsFlagTag) {
+			return nil
+		}
+
+		total++
+
+		run, ok := runs[record.Run]
+		if !ok {
+			run = &storeRunStats{run: record.Run, command: record.Command, started: record.Time, tags: record.Tags, hosts: make(map[string]bool)}
+			runs[record.Run] = run
+		}
+		run.results++
+		if record.Time.Before(run.started) {
+			run.started = record.Time
+		}
+
+		if !record.Success {
+			return nil
+		}
+
+		successes++
+		run.successes++
+		run.hosts[record.Host] = true
+
+		if cdn := record.Extra["cdn"]; cdn != "" {
+			byCDN[cdn]++
+		}
+		if country := record.Extra["country"]; country != "" {
+			byCountry[country]++
+		}
+
+		day := record.Time.Local().Format("2006-01-02")
+		if byDay[day] == nil {
+			byDay[day] = make(map[string]bool)
+		}
+		byDay[day][record.Host] = true
+
+		return nil
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	if total == 0 {
+		fmt.Printf("no results in %s\n", store.Path())
+		return
+	}
+
+	fmt.Printf("%d results, %d successful, %d runs in %s\n", total, successes, len(runs), store.Path())
+
+	runList := make([]*storeRunStats, 0, len(runs))
+	for _, run := range runs {
+		runList = append(runList, run)
+	}
+	sort.Slice(runList, func(i, j int) bool {
+		return runList[i].started.After(runList[j].started)
+	})
+	if statsFlagRuns > 0 && len(runList) > statsFlagRuns {
+		runList = runList[:statsFlagRuns]
+	}
+
+	fmt.Printf("\nRuns\n")
+	fmt.Printf("  %-24s %-8s %-19s %8s %8s %8s  %s\n", "Run", "Command", "Started", "Results", "Success", "Hosts", "Tags")
+	for _, run := range runList {
+		fmt.Printf("  %-24s %-8s %-19s %8d %8d %8d  %s\n", run.run, run.command, run.started.Local().Format("2006-01-02 15:04:05"), run.results, run.successes, len(run.hosts), strings.Join(run.tags, ","))
+	}
+
+	printCounts("Successes per CDN", byCDN)
+	printCounts("Successes per country", byCountry)
+
+	days := make([]string, 0, len(byDay))
+	for day := range byDay {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	if statsFlagDays > 0 && len(days) > statsFlagDays {
+		days = days[len(days)-statsFlagDays:]
+	}
+
+	if len(days) > 0 {
+		fmt.Printf("\nWorking hosts per day\n")
+		for _, day := range days {
+			fmt.Printf("  %-24s %d\n", day, len(byDay[day]))
+		}
+	}
+}
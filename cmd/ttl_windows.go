@@ -0,0 +1,11 @@
+//go:build windows
+
+package cmd
+
+import (
+	"syscall"
+)
+
+func setSocketTTL(fd uintptr, ttl int) error {
+	return syscall.SetsockoptInt(syscall.Handle(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
+}
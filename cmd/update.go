@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Refresh the bundled top-ports and CDN provider datasets into the local data cache.",
+	Run:   runUpdate,
+}
+
+var (
+	updateFlagSource  string
+	updateFlagTimeout int
+)
+
+// embeddedDatasets are the datasets update can refresh. Nothing in the tree
+// uses public resolver lists or block-page signatures yet, so they have no
+// entry here.
+var embeddedDatasets = map[string][]byte{
+	"top-ports.json":     embeddedTopPorts,
+	"cdn-providers.json": embeddedCDNProviders,
+}
+
+type datasetVersion struct {
+	Version string    `json:"version"`
+	Source  string    `json:"source"`
+	Updated time.Time `json:"updated"`
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+
+	updateCmd.Flags().StringVar(&updateFlagSource, "source", "https://raw.githubusercontent.com/ayanrajpoot10/bugscanx-go/main/cmd/data/", "base url the datasets are fetched from")
+	updateCmd.Flags().IntVar(&updateFlagTimeout, "timeout", 30, "download timeout in seconds")
+}
+
+func dataCacheDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "data"), nil
+}
+
+// loadDataset returns the cached copy of a dataset written by update, or the
+// embedded snapshot when there is none or --offline is set.
+func loadDataset(name string) []byte {
+	if !globalFlagOffline {
+		if dir, err := dataCacheDir(); err == nil {
+			if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+				return data
+			}
+		}
+	}
+	return embeddedDatasets[name]
+}
+
+func loadDatasetManifest(dir string) map[string]datasetVersion {
+	manifest := make(map[string]datasetVersion)
+
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return manifest
+	}
+	json.Unmarshal(data, &manifest)
+
+	return manifest
+}
+
+func fetchDataset(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("invalid dataset: not json")
+	}
+
+	return data, nil
+}
+
+func writeFileAtomic(filename string, data []byte) error {
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+func runUpdate(cmd *cobra.Command, args []string) {
+	if globalFlagOffline {
+		fatal(fmt.Errorf("update needs network access, remove --offline"))
+	}
+
+	dir, err := dataCacheDir()
+	if err != nil {
+		fatal(err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fatal(err)
+	}
+
+	manifest := loadDatasetManifest(dir)
+	client := &http.Client{Timeout: time.Duration(updateFlagTimeout) * time.Second}
+
+	names := make([]string, 0, len(embeddedDatasets))
+	for name := range embeddedDatasets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	failed := 0
+	for _, name := range names {
+		url := updateFlagSource + name
+
+		data, err := fetchDataset(client, url)
+		if err != nil {
+			fmt.Printf("%-20s  failed: %s\n", name, err)
+			failed++
+			continue
+		}
+
+		sum := sha256.Sum256(data)
+		version := hex.EncodeToString(sum[:])[:12]
+
+		if manifest[name].Version == version {
+			fmt.Printf("%-20s  %s  unchanged\n", name, version)
+			continue
+		}
+
+		if err := writeFileAtomic(filepath.Join(dir, name), data); err != nil {
+			fatal(err)
+		}
+		manifest[name] = datasetVersion{Version: version, Source: url, Updated: time.Now().UTC()}
+
+		fmt.Printf("%-20s  %s  updated\n", name, version)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		fatal(err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "manifest.json"), data); err != nil {
+		fatal(err)
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
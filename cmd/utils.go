@@ -2,15 +2,66 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"iter"
 	"net"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ayanrajpoot10/bugscanx-go/pkg/queuescanner"
+	"github.com/ayanrajpoot10/bugscanx-go/pkg/resultstore"
 )
 
 var ipRegex = regexp.MustCompile(`\d+$`)
 
+var reportedHeaders = []string{
+	"location",
+	"server",
+	"via",
+	"forwarded",
+	"x-real-ip",
+	"x-cache",
+	"x-served-by",
+	"cf-cache-status",
+}
+
+// reportedHeaderPrefixes are header families reported by any member,
+// such as x-cache-hits or x-forwarded-proto.
+var reportedHeaderPrefixes = []string{
+	"x-cache-",
+	"x-forwarded-",
+}
+
+func isReportedHeader(line string) bool {
+	name, _, found := strings.Cut(line, ":")
+	if !found {
+		return false
+	}
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, header := range reportedHeaders {
+		if name == header {
+			return true
+		}
+	}
+	for _, prefix := range reportedHeaderPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func ReadFile(filename string) ([]string, error) {
 	var reader io.Reader
 
@@ -37,9 +88,8 @@ func ReadFile(filename string) ([]string, error) {
 	var lines []string
 	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
-		line := scanner.Text()
-		if line != "" {
-			lines = append(lines, line)
+		if line, ok := queuescanner.NormalizeHost(scanner.Text()); ok {
+			lines = append(lines, toASCIIHost(line))
 		}
 	}
 
@@ -50,6 +100,33 @@ func ReadFile(filename string) ([]string, error) {
 	return lines, nil
 }
 
+// streamInput yields hosts from piped stdin as they arrive instead of reading
+// them all up front. It reports false for files, and under --resume, which
+// needs the whole list to find its checkpoint.
+func streamInput(filename string) (iter.Seq[string], bool) {
+	if (filename != "" && filename != "-") || globalFlagResume {
+		return nil, false
+	}
+
+	stat, err := os.Stdin.Stat()
+	if err != nil || (stat.Mode()&os.ModeCharDevice) != 0 {
+		return nil, false
+	}
+
+	return func(yield func(string) bool) {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			line, ok := queuescanner.NormalizeHost(scanner.Text())
+			if ok && !yield(toASCIIHost(line)) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			fatal(err)
+		}
+	}, true
+}
+
 func ipInc(ip net.IP) {
 	for j := len(ip) - 1; j >= 0; j-- {
 		ip[j]++
@@ -59,21 +136,360 @@ func ipInc(ip net.IP) {
 	}
 }
 
-func IPsFromCIDR(cidr string) ([]string, error) {
+// IPsFromCIDR yields the addresses in cidr one at a time, leaving out the
+// network and broadcast addresses of anything wider than a single host, along
+// with how many it will yield (-1 when that doesn't fit in an int).
+func IPsFromCIDR(cidr string) (iter.Seq[string], int, error) {
 	ip, ipnet, err := net.ParseCIDR(cidr)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	hostBits := bits - ones
+	total := -1
+	if hostBits < 62 {
+		total = 1
+		if hostBits > 0 {
+			total = 1<<hostBits - 2
+		}
+	}
+
+	first := ip.Mask(ipnet.Mask)
+	return func(yield func(string) bool) {
+		current := append(net.IP(nil), first...)
+		if hostBits == 0 {
+			yield(current.String())
+			return
+		}
+
+		ipInc(current)
+		for {
+			next := append(net.IP(nil), current...)
+			ipInc(next)
+			if !ipnet.Contains(next) || next.Equal(first) {
+				// current is the broadcast address
+				return
+			}
+			if !yield(current.String()) {
+				return
+			}
+			current = next
+		}
+	}, total, nil
+}
+
+func formatLatency(d time.Duration) string {
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
+
+// hostContext bounds everything done for a single host when --host-timeout is set,
+// under parent so an abandoned scan aborts it too.
+func hostContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if globalFlagHostTimeout > 0 {
+		return context.WithTimeout(parent, globalFlagHostTimeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// setHostDeadline applies hostDeadline to conn and cuts it short once ctx is
+// cancelled, since deadline-driven reads don't watch the context themselves.
+func setHostDeadline(ctx context.Context, conn net.Conn, timeout time.Duration) {
+	conn.SetDeadline(hostDeadline(ctx, timeout))
+	context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+}
+
+// sleepContext waits for d, reporting false if ctx ends first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// fallbackContext applies a fixed internal timeout only when no host deadline is set.
+func fallbackContext(parent context.Context, fallback time.Duration) (context.Context, context.CancelFunc) {
+	if globalFlagHostTimeout > 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, fallback)
+}
+
+func hostDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if hostDeadline, ok := ctx.Deadline(); ok && hostDeadline.Before(deadline) {
+		return hostDeadline
+	}
+	return deadline
+}
+
+var outputMu sync.Mutex
+
+func appendToFile(filename string, lines ...string) error {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for _, line := range lines {
+		if _, err := file.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type sortableResult struct {
+	ip      string
+	host    string
+	latency time.Duration
+	status  int
+	line    string
+}
+
+type sortableResults struct {
+	mu      sync.Mutex
+	results []sortableResult
+}
+
+var scanResults sortableResults
+
+func (r *sortableResults) add(result sortableResult) {
+	r.mu.Lock()
+	r.results = append(r.results, result)
+	r.mu.Unlock()
+}
+
+func (r *sortableResults) lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lines := make([]string, 0, len(r.results))
+	for _, result := range r.results {
+		lines = append(lines, result.line)
+	}
+
+	return lines
+}
+
+func compareIP(a string, b string) int {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	switch {
+	case ipA == nil && ipB == nil:
+		return strings.Compare(a, b)
+	case ipA == nil:
+		return 1
+	case ipB == nil:
+		return -1
+	}
+	return bytes.Compare(ipA.To16(), ipB.To16())
+}
+
+func (r *sortableResults) sortedLines(key string) []string {
+	r.mu.Lock()
+	sort.SliceStable(r.results, func(i, j int) bool {
+		a, b := r.results[i], r.results[j]
+		switch key {
+		case "ip":
+			return compareIP(a.ip, b.ip) < 0
+		case "host":
+			return a.host < b.host
+		case "status":
+			return a.status < b.status
+		default:
+			return a.latency < b.latency
+		}
+	})
+	r.mu.Unlock()
+
+	return r.lines()
+}
+
+func validateSortKey() error {
+	switch globalFlagSort {
+	case "", "ip", "host", "latency", "status":
+	case "rtt":
+		globalFlagSort = "latency"
+	default:
+		return fmt.Errorf("invalid sort: %s (expected ip, host, latency or status)", globalFlagSort)
 	}
+	return nil
+}
+
+func collectResult(result sortableResult) {
+	if globalFlagSort != "" {
+		scanResults.add(result)
+	}
+}
+
+// scanOutputFile returns the file the scanner should append to while running;
+// sorted output is written once at the end by writeSortedOutput instead.
+func scanOutputFile(output string) string {
+	if globalFlagSort != "" {
+		return ""
+	}
+	return output
+}
+
+// resultFormat is how results are rendered into the output file, "table"
+// unless the command offers --output-format.
+var resultFormat string
 
-	var ips []string
-	for currentIP := ip.Mask(ipnet.Mask); ipnet.Contains(currentIP); ipInc(currentIP) {
-		ips = append(ips, currentIP.String())
+func writeSortedOutput(output string) {
+	if globalFlagSort == "" || output == "" {
+		return
+	}
+
+	lines := scanResults.sortedLines(globalFlagSort)
+	if info, err := os.Stat(output); resultFormat == "csv" && (err != nil || info.Size() == 0) {
+		lines = append([]string{queuescanner.CSVHeader}, lines...)
+	}
+
+	if err := appendToFile(output, lines...); err != nil {
+		fatal(err)
+	}
+}
+
+func newScanner(scanFunc func(c *queuescanner.Ctx, host string)) *queuescanner.QueueScanner {
+	qs := queuescanner.New(globalFlagThreads, trackProbes(scanFunc))
+	qs.SetRetries(globalFlagRetries, globalFlagRetryBackoff)
+	qs.SetRate(globalFlagRate)
+	qs.SetPacing(globalFlagPace, globalFlagJitter)
+	qs.SetMultiline(globalFlagStatMultiline)
+	qs.SetLogReplay(globalFlagLogTail)
+	qs.SetInterleave(globalFlagInterleave)
+	qs.SetMaxRuntime(globalFlagMaxRuntime)
+	qs.SetMaxSuccess(globalFlagMaxSuccess)
+	qs.SetTaskTimeout(globalFlagTaskTimeout)
+	if globalFlagSink != "" {
+		qs.AddWriter(queuescanner.NewSinkWriter(globalFlagSink))
+	}
+
+	if globalFlagFailedOutput != "" {
+		qs.SetOnDone(finishTask)
+	}
+	if jsonLogOut != nil {
+		qs.SetJSONLog(jsonLogOut)
+	}
+	// falls back to the line output when not on a terminal
+	tuiActive = qs.SetTUI(globalFlagTUI)
+
+	metricsMu.Lock()
+	metricsScanner = qs
+	metricsMu.Unlock()
+
+	return qs
+}
+
+var tuiActive bool
+
+// printHeader prints the column headings of a scan's results, or hands them
+// to the TUI which draws them above its results.
+func printHeader(qs *queuescanner.QueueScanner, lines ...string) {
+	qs.SetHeader(lines...)
+	if tuiActive {
+		return
+	}
+	for _, line := range lines {
+		fmt.Println(line)
 	}
-	if len(ips) <= 1 {
-		return ips, nil
+}
+
+func suffixFilename(filename string, suffix string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "-" + suffix + ext
+}
+
+func openStore() (*resultstore.Store, error) {
+	path := globalFlagStore
+	if path == "" {
+		dir, err := configDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, "results.jsonl")
+	}
+	return resultstore.Open(path)
+}
+
+var (
+	recordStore *resultstore.Store
+	recordRun   string
+)
+
+func openRecordStore() error {
+	if !globalFlagRecord {
+		return nil
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	recordStore = store
+	recordRun = resultstore.NewRunID()
+
+	return nil
+}
+
+// recordResult appends a successful scan result to the result store when --record is set.
+func recordResult(record resultstore.Record) {
+	if recordStore == nil {
+		return
+	}
+
+	record.Time = time.Now().UTC()
+	record.Run = recordRun
+	record.Success = true
+	record.Tags = globalFlagTags
+
+	recordStore.Append(record)
+}
+
+// reportResult feeds a success of task to --sort and the --record store.
+func reportResult(command string, task string, result *queuescanner.Result) {
+	observeLatency(command, result.Latency)
+	noteSuccess(task)
+
+	ip := result.IP
+	if ip == "" {
+		ip = result.Host
+	}
+	collectResult(sortableResult{ip: ip, host: result.Host, latency: result.Latency, status: result.Status, line: result.Render(resultFormat)})
+
+	extra := make(map[string]string, len(result.Extra)+2)
+	for key, value := range result.Extra {
+		extra[key] = value
+	}
+	if result.Status != 0 {
+		extra["status"] = strconv.Itoa(result.Status)
+	}
+	if result.Server != "" {
+		extra["server"] = result.Server
+	}
+
+	recordResult(resultstore.Record{Command: command, Host: result.Host, IP: result.IP, Port: result.Port, LatencyMs: result.Latency.Milliseconds(), Extra: extra})
+}
+
+func statusFromLine(line string) int {
+	parts := strings.Fields(line)
+	if len(parts) < 2 {
+		return 0
 	}
 
-	return ips[1 : len(ips)-1], nil
+	status, _ := strconv.Atoi(parts[1])
+	return status
 }
 
 func fatal(err error) {
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIPsFromCIDR(t *testing.T) {
+	tests := []struct {
+		cidr  string
+		total int
+		first []string
+	}{
+		{"192.168.1.7/32", 1, []string{"192.168.1.7"}},
+		{"192.168.1.6/31", 0, nil},
+		{"192.168.1.5/30", 2, []string{"192.168.1.5", "192.168.1.6"}},
+		{"10.0.0.0/24", 254, []string{"10.0.0.1", "10.0.0.2"}},
+		{"10.0.0.0/23", 510, []string{"10.0.0.1", "10.0.0.2"}},
+		{"2001:db8::1/128", 1, []string{"2001:db8::1"}},
+		{"2001:db8::/126", 2, []string{"2001:db8::1", "2001:db8::2"}},
+	}
+
+	for _, test := range tests {
+		ips, total, err := IPsFromCIDR(test.cidr)
+		if err != nil {
+			t.Errorf("IPsFromCIDR(%q) error: %v", test.cidr, err)
+			continue
+		}
+		if total != test.total {
+			t.Errorf("IPsFromCIDR(%q) total = %d, want %d", test.cidr, total, test.total)
+		}
+
+		var got []string
+		for ip := range ips {
+			got = append(got, ip)
+		}
+		if len(got) != total {
+			t.Errorf("IPsFromCIDR(%q) yields %d addresses, but its total is %d", test.cidr, len(got), total)
+		}
+		if !slices.Equal(got[:min(len(got), len(test.first))], test.first) {
+			t.Errorf("IPsFromCIDR(%q) starts %v, want %v", test.cidr, got, test.first)
+		}
+	}
+
+	// prefixes too wide to count still yield lazily
+	ips, total, err := IPsFromCIDR("2001:db8::/32")
+	if err != nil || total != -1 {
+		t.Fatalf("IPsFromCIDR(/32 v6) = %d, %v, want -1 and no error", total, err)
+	}
+	for ip := range ips {
+		if ip != "2001:db8::1" {
+			t.Errorf("IPsFromCIDR(/32 v6) starts at %s", ip)
+		}
+		break
+	}
+
+	if _, _, err := IPsFromCIDR("10.0.0.0/33"); err == nil {
+		t.Error("IPsFromCIDR(10.0.0.0/33) succeeded, want an error")
+	}
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type dialFunc func(ctx context.Context, network string, address string) (net.Conn, error)
+
+// scanDial is what every scan connects through, the --via chain when one is set.
+var scanDial dialFunc = baseDial
+
+func baseDial(ctx context.Context, network string, address string) (net.Conn, error) {
+	addresses, err := resolveAddress(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	for _, addr := range addresses {
+		conn, err = (&net.Dialer{}).DialContext(ctx, network, addr)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return throttleConn(conn), nil
+}
+
+func dialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	return scanDial(ctx, network, address)
+}
+
+// setupViaChain nests the proxies in order, so each hop is dialed through the previous one.
+func setupViaChain() error {
+	dial := dialFunc(baseDial)
+	for _, rawURL := range globalFlagVia {
+		next, err := newViaDialer(rawURL, dial)
+		if err != nil {
+			return err
+		}
+		dial = next
+	}
+
+	scanDial = dial
+
+	return nil
+}
+
+func newViaDialer(rawURL string, forward dialFunc) (dialFunc, error) {
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid via proxy: %w", err)
+	}
+
+	if proxyURL.Host == "" || proxyURL.Port() == "" {
+		return nil, fmt.Errorf("invalid via proxy: %s (expected scheme://host:port)", rawURL)
+	}
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		return func(ctx context.Context, network string, address string) (net.Conn, error) {
+			conn, err := forward(ctx, "tcp", proxyURL.Host)
+			if err != nil {
+				return nil, err
+			}
+			if err := withConnContext(ctx, conn, func() error { return socks5Connect(conn, proxyURL.User, address) }); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		}, nil
+	case "http":
+		return func(ctx context.Context, network string, address string) (net.Conn, error) {
+			conn, err := forward(ctx, "tcp", proxyURL.Host)
+			if err != nil {
+				return nil, err
+			}
+			if err := withConnContext(ctx, conn, func() error { return httpConnect(conn, proxyURL.User, address) }); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported via proxy scheme: %s", proxyURL.Scheme)
+	}
+}
+
+func withConnContext(ctx context.Context, conn net.Conn, handshake func() error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	return handshake()
+}
+
+func socks5Connect(conn net.Conn, user *url.Userinfo, address string) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+
+	methods := []byte{0x00}
+	if user != nil {
+		methods = []byte{0x00, 0x02}
+	}
+
+	greeting := append([]byte{0x05, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 {
+		return fmt.Errorf("socks5: unexpected version %d", reply[0])
+	}
+
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if user == nil {
+			return fmt.Errorf("socks5: proxy requires authentication")
+		}
+		password, _ := user.Password()
+		auth := []byte{0x01, byte(len(user.Username()))}
+		auth = append(auth, user.Username()...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return fmt.Errorf("socks5: authentication failed")
+		}
+	default:
+		return fmt.Errorf("socks5: no acceptable authentication method")
+	}
+
+	request := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			request = append(request, 0x01)
+			request = append(request, ip4...)
+		} else {
+			request = append(request, 0x04)
+			request = append(request, ip.To16()...)
+		}
+	} else {
+		request = append(request, 0x03, byte(len(host)))
+		request = append(request, host...)
+	}
+	request = binary.BigEndian.AppendUint16(request, uint16(port))
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != 0x00 {
+		return fmt.Errorf("socks5: connect failed with code %d", header[1])
+	}
+
+	var skip int
+	switch header[3] {
+	case 0x01:
+		skip = net.IPv4len
+	case 0x04:
+		skip = net.IPv6len
+	case 0x03:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		skip = int(length[0])
+	default:
+		return fmt.Errorf("socks5: unknown address type %d", header[3])
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
+
+func httpConnect(conn net.Conn, user *url.Userinfo, address string) error {
+	request := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", address, address)
+	if user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		request += "Proxy-Authorization: Basic " + credentials + "\r\n"
+	}
+	request += "\r\n"
+
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return err
+	}
+
+	// read byte by byte so nothing past the header is consumed from the tunnel
+	var response []byte
+	buffer := make([]byte, 1)
+	for !strings.HasSuffix(string(response), "\r\n\r\n") {
+		if len(response) > 8192 {
+			return fmt.Errorf("http connect: response header too large")
+		}
+		if _, err := conn.Read(buffer); err != nil {
+			return err
+		}
+		response = append(response, buffer[0])
+	}
+
+	statusLine, _, _ := strings.Cut(string(response), "\r\n")
+	parts := strings.Fields(statusLine)
+	if len(parts) < 2 || parts[1] != "200" {
+		return fmt.Errorf("http connect: %s", statusLine)
+	}
+
+	return nil
+}
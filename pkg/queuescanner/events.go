@@ -0,0 +1,66 @@
+package queuescanner
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// SetJSONLog replaces the terminal output with one JSON event per line on w:
+// start, log, progress, success, error, checkpoint and finish.
+func (qs *QueueScanner) SetJSONLog(w io.Writer) {
+	qs.ctx.jsonOut = w
+}
+
+// Event emits a custom event in JSON log mode and does nothing otherwise.
+func (qs *QueueScanner) Event(event string, fields map[string]any) {
+	qs.ctx.emit(event, fields)
+}
+
+func (ctx *Ctx) emit(event string, fields map[string]any) {
+	if ctx.jsonOut == nil {
+		return
+	}
+
+	line := map[string]any{
+		"event": event,
+		"time":  time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	for key, value := range fields {
+		line[key] = value
+	}
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+
+	ctx.printMu.Lock()
+	ctx.jsonOut.Write(append(data, '\n'))
+	ctx.printMu.Unlock()
+}
+
+func (ctx *Ctx) statFields() map[string]any {
+	return map[string]any{
+		"total":      atomic.LoadInt64(&ctx.total),
+		"queued":     atomic.LoadInt64(&ctx.queued),
+		"completed":  atomic.LoadInt64(&ctx.ScanComplete),
+		"success":    atomic.LoadInt64(&ctx.SuccessCount),
+		"duplicates": atomic.LoadInt64(&ctx.DuplicateCount),
+		"retries":    atomic.LoadInt64(&ctx.RetryCount),
+		"active":     atomic.LoadInt64(&ctx.active),
+		"elapsed_ms": (nowNano() - ctx.startTime) / int64(time.Millisecond),
+	}
+}
+
+func stripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	return ansiEscape.ReplaceAllString(s, "")
+}
@@ -0,0 +1,84 @@
+package queuescanner
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Pause stops workers from starting new hosts until Resume; probes already
+// running finish normally.
+func (qs *QueueScanner) Pause() {
+	qs.pauseMu.Lock()
+	qs.paused = true
+	qs.pauseMu.Unlock()
+}
+
+func (qs *QueueScanner) Resume() {
+	qs.pauseMu.Lock()
+	qs.paused = false
+	qs.pauseMu.Unlock()
+	qs.pauseCond.Broadcast()
+}
+
+func (qs *QueueScanner) waitIfPaused() {
+	qs.pauseMu.Lock()
+	for qs.paused && !qs.stopped() {
+		qs.pauseCond.Wait()
+	}
+	qs.pauseMu.Unlock()
+}
+
+// threadStep is how many workers one + or - press adds or removes.
+func threadStep(threads int) int {
+	return max(threads/10, 1)
+}
+
+// watchKeys puts an interactive terminal into raw mode and maps p to pause,
+// r to resume and +/- to add or remove workers; the TUI takes / and the
+// filter typed after it. Raw mode swallows Ctrl+C, so it is forwarded to interrupt.
+// The returned func restores the terminal.
+func (qs *QueueScanner) watchKeys(interrupt chan<- os.Signal) func() {
+	stdin := int(os.Stdin.Fd())
+	if !term.IsTerminal(stdin) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return func() {}
+	}
+
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return func() {}
+	}
+
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := os.Stdin.Read(buf); err != nil {
+				return
+			}
+
+			if buf[0] != 3 && qs.ctx.tuiKey(buf[0]) {
+				continue
+			}
+
+			switch buf[0] {
+			case 'p', 'P':
+				qs.Pause()
+				qs.ctx.Log("paused, press r to resume")
+			case 'r', 'R':
+				qs.Resume()
+				qs.ctx.Log("resumed")
+			case '+', '=':
+				qs.SetThreads(qs.Threads() + threadStep(qs.Threads()))
+				qs.ctx.Log(fmt.Sprintf("threads: %d", qs.Threads()))
+			case '-', '_':
+				qs.SetThreads(qs.Threads() - threadStep(qs.Threads()))
+				qs.ctx.Log(fmt.Sprintf("threads: %d", qs.Threads()))
+			case 3: // Ctrl+C
+				interrupt <- os.Interrupt
+			}
+		}
+	}()
+
+	return func() { term.Restore(stdin, state) }
+}
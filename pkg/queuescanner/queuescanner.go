@@ -1,9 +1,15 @@
 package queuescanner
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"iter"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -13,23 +19,87 @@ import (
 )
 
 type Ctx struct {
-	ScanComplete int64
-	SuccessCount int64
-	startTime    int64
-	lastStatTime int64
-	statInterval int64 // in nanoseconds
+	*scanState
 
-	hostList   []string
-	mu         sync.Mutex
-	OutputFile string
+	// set on the Ctx a task gets under a task timeout, so an abandoned task
+	// is cancelled and whatever it still reports is dropped
+	task      context.Context
+	abandoned *atomic.Bool
+}
+
+// scanState is shared by every Ctx of a scan.
+type scanState struct {
+	ScanComplete   int64
+	SuccessCount   int64
+	DuplicateCount int64
+	RetryCount     int64
+	startTime      int64
+	lastStatTime   int64
+	statInterval   int64 // in nanoseconds
+	active         int64
+	threads        int64 // target worker count, changed by SetThreads
+	multiline      bool
+	printMu        sync.Mutex
+	jsonOut        io.Writer // set in JSON log mode, replaces the terminal output
+	tui            *tuiState // set in TUI mode, guarded by printMu
+	logRing        []string  // last messages passed to Log, replayed at the end
+	logNext        int
+	logKept        int
+
+	total       int64 // -1 while a streamed source is still producing
+	queued      int64
+	mu          sync.Mutex
+	OutputFile  string
+	format      string
+	writers     []ResultWriter
+	writeFailed bool
+	tags        map[string]int64 // successes by ScanSuccessTagged tag
+	maxSuccess  int64
+	onEnough    func() // called once SuccessCount reaches maxSuccess
+
+	seen    sync.Map
+	retries sync.Map
+	pacer   *destPacer
+
+	base   context.Context
+	cancel context.CancelFunc
 }
 
 type QueueScanner struct {
-	threads  int
-	scanFunc func(c *Ctx, host string)
-	queue    chan string
-	wg       sync.WaitGroup
-	ctx      *Ctx
+	threads int
+	header  []string
+	// workers is how many run loops are alive, guarded by workersMu
+	workersMu sync.Mutex
+	workers   int
+	scanFunc  func(c *Ctx, host string)
+	queue     chan string
+	wg        sync.WaitGroup
+	ctx       *Ctx
+	hosts     []string
+	added     map[string]int // host -> priority
+	source    iter.Seq[string]
+	// -1 when the source can't say how many hosts it will produce
+	sourceTotal int
+	interleave  bool
+
+	retries int
+	backoff time.Duration
+	limiter *tokenBucket
+
+	checkpointPath string
+	checkpointMu   sync.Mutex
+	checkpoint     *os.File
+
+	stop       chan struct{}
+	stopOnce   sync.Once
+	maxRuntime time.Duration
+	// a probe running longer than this is abandoned, 0 waits forever
+	taskTimeout time.Duration
+	onDone      func(host string)
+
+	pauseMu   sync.Mutex
+	pauseCond *sync.Cond
+	paused    bool
 }
 
 func nowNano() int64 {
@@ -44,16 +114,57 @@ func formatETA(seconds float64) string {
 	return d.Truncate(time.Second).String()
 }
 
-func hideCursor() {
-	fmt.Print("\033[?25l")
+func (ctx *Ctx) hideCursor() {
+	if ctx.jsonOut == nil {
+		fmt.Print("\033[?25l")
+	}
 }
 
-func showCursor() {
-	fmt.Print("\033[?25h")
+func (ctx *Ctx) showCursor() {
+	if ctx.jsonOut == nil {
+		fmt.Print("\033[?25h")
+	}
 }
 
 func (ctx *Ctx) Log(a ...any) {
-	fmt.Printf("\r\033[2K%s\n", fmt.Sprint(a...))
+	if ctx.jsonOut != nil {
+		ctx.emit("log", map[string]any{"message": stripANSI(fmt.Sprint(a...))})
+		return
+	}
+
+	ctx.printMu.Lock()
+	defer ctx.printMu.Unlock()
+
+	message := fmt.Sprint(a...)
+	if len(ctx.logRing) > 0 {
+		ctx.logRing[ctx.logNext] = message
+		ctx.logNext = (ctx.logNext + 1) % len(ctx.logRing)
+		ctx.logKept = min(ctx.logKept+1, len(ctx.logRing))
+	}
+	if ctx.tui != nil {
+		ctx.tui.add(stripANSI(message))
+		ctx.drawTUI(false)
+		return
+	}
+
+	// clear to the end of the screen so a multi-line status goes too
+	fmt.Printf("\r\033[J%s\n", message)
+}
+
+// replayLog prints the messages kept by SetLogReplay, oldest first.
+func (ctx *Ctx) replayLog() {
+	ctx.printMu.Lock()
+	defer ctx.printMu.Unlock()
+
+	if ctx.logKept == 0 {
+		return
+	}
+
+	fmt.Print("\r\033[Jrecent messages:\n")
+	start := ctx.logNext - ctx.logKept + len(ctx.logRing)
+	for i := 0; i < ctx.logKept; i++ {
+		fmt.Println(ctx.logRing[(start+i)%len(ctx.logRing)])
+	}
 }
 
 func (ctx *Ctx) LogStat() {
@@ -65,57 +176,245 @@ func (ctx *Ctx) LogStat() {
 		atomic.StoreInt64(&ctx.lastStatTime, now)
 	}
 
+	ctx.printStat(false)
+}
+
+// printStat draws the status. Unless final, the cursor is left at the start
+// of the status so the next Log or LogStat overwrites it.
+func (ctx *Ctx) printStat(final bool) {
+	if ctx.jsonOut != nil {
+		// the finish event carries the final numbers
+		if !final {
+			ctx.emit("progress", ctx.statFields())
+		}
+		return
+	}
+	if ctx.tui != nil {
+		// the normal screen gets the final status once the TUI is closed
+		if !final {
+			ctx.printMu.Lock()
+			ctx.drawTUI(true)
+			ctx.printMu.Unlock()
+		}
+		return
+	}
+
+	lines := ctx.statLines()
+
+	if termWidth, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		width := termWidth - 3
+		for i, line := range lines {
+			if width > 0 && len(line) >= width {
+				lines[i] = line[:width] + "..."
+			}
+		}
+	}
+
+	ctx.printMu.Lock()
+	defer ctx.printMu.Unlock()
+
+	// the terminal may be in raw mode for key handling, so newlines
+	// need their own carriage return
+	fmt.Print("\r\033[J", strings.Join(lines, "\r\n"))
+	if final {
+		fmt.Print("\r\n")
+	} else if len(lines) > 1 {
+		fmt.Printf("\033[%dA\r", len(lines)-1)
+	} else {
+		fmt.Print("\r")
+	}
+}
+
+// statLines formats the status, one or two lines depending on multiline.
+func (ctx *Ctx) statLines() []string {
 	scanSuccess := atomic.LoadInt64(&ctx.SuccessCount)
 	scanComplete := atomic.LoadInt64(&ctx.ScanComplete)
-	scanCompletePercentage := float64(scanComplete) / float64(len(ctx.hostList)) * 100
+	total := atomic.LoadInt64(&ctx.total)
 
 	elapsed := float64(nowNano()-ctx.startTime) / 1e9 // seconds
-	avgPerItem := elapsed / float64(scanComplete)
-	remaining := float64(len(ctx.hostList) - int(scanComplete))
-	etaSec := avgPerItem * remaining
-	eta := formatETA(etaSec)
-
-	status := fmt.Sprintf(
-		"%.2f%% - C: %d / %d - S: %d - ETA: %s",
-		scanCompletePercentage,
-		scanComplete,
-		len(ctx.hostList),
-		scanSuccess,
-		eta,
-	)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(scanComplete) / elapsed
+	}
 
-	if termWidth, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
-		width := termWidth - 3
-		if len(status) >= width {
-			status = status[:width] + "..."
+	successPercentage := 0.0
+	if scanComplete > 0 {
+		successPercentage = float64(scanSuccess) / float64(scanComplete) * 100
+	}
+
+	progress := fmt.Sprintf("C: %d / %d+", scanComplete, atomic.LoadInt64(&ctx.queued))
+	eta := "--"
+	if total >= 0 {
+		scanCompletePercentage := 100.0
+		if total > 0 {
+			scanCompletePercentage = float64(scanComplete) / float64(total) * 100
+		}
+		progress = fmt.Sprintf("%.2f%% - C: %d / %d", scanCompletePercentage, scanComplete, total)
+
+		avgPerItem := elapsed / float64(scanComplete)
+		eta = formatETA(avgPerItem * float64(total-scanComplete))
+	}
+
+	var extra string
+	if duplicates := atomic.LoadInt64(&ctx.DuplicateCount); duplicates > 0 {
+		extra += fmt.Sprintf(" - D: %d", duplicates)
+	}
+	if retries := atomic.LoadInt64(&ctx.RetryCount); retries > 0 {
+		extra += fmt.Sprintf(" - R: %d", retries)
+	}
+
+	activity := fmt.Sprintf("%.1f/s - W: %d/%d - T: %s", rate, atomic.LoadInt64(&ctx.active), atomic.LoadInt64(&ctx.threads), formatETA(elapsed))
+
+	var lines []string
+	if ctx.multiline {
+		lines = []string{
+			progress + " - ETA: " + eta,
+			fmt.Sprintf("S: %d (%.1f%%)", scanSuccess, successPercentage) + extra + " - " + activity,
+		}
+	} else {
+		lines = []string{
+			fmt.Sprintf("%s - S: %d (%.1f%%) - %s - ETA: %s", progress, scanSuccess, successPercentage, activity, eta) + extra,
+		}
+	}
+	return lines
+}
+
+func (ctx *Ctx) ScanSuccess(result *Result) {
+	if ctx.late() {
+		return
+	}
+
+	ctx.mu.Lock()
+	for _, writer := range ctx.writers {
+		if err := writer.WriteResult(result); err != nil && !ctx.writeFailed {
+			// report the first failure only, a dead sink would flood the log
+			ctx.writeFailed = true
+			ctx.Log(fmt.Sprintf("result output failed: %v", err))
 		}
 	}
+	ctx.mu.Unlock()
+
+	if atomic.AddInt64(&ctx.SuccessCount, 1) == ctx.maxSuccess && ctx.onEnough != nil {
+		ctx.onEnough()
+	}
+
+	if ctx.jsonOut != nil {
+		ctx.emit("success", map[string]any{"result": json.RawMessage(result.Render("json"))})
+	}
+}
+
+// ScanSuccessTagged is ScanSuccess that also counts the result under tag,
+// e.g. "port 443", for the breakdown printed when the scan ends.
+func (ctx *Ctx) ScanSuccessTagged(tag string, result *Result) {
+	if ctx.late() {
+		return
+	}
+
+	ctx.mu.Lock()
+	if ctx.tags == nil {
+		ctx.tags = make(map[string]int64)
+	}
+	ctx.tags[tag]++
+	ctx.mu.Unlock()
+
+	ctx.ScanSuccess(result)
+}
+
+// Tags returns the success count per ScanSuccessTagged tag.
+func (qs *QueueScanner) Tags() map[string]int64 {
+	qs.ctx.mu.Lock()
+	defer qs.ctx.mu.Unlock()
 
-	fmt.Print("\r\033[2K", status, "\r")
+	tags := make(map[string]int64, len(qs.ctx.tags))
+	for tag, count := range qs.ctx.tags {
+		tags[tag] = count
+	}
+	return tags
 }
 
-func (ctx *Ctx) ScanSuccess(result any) {
-	if str, ok := result.(string); ok && ctx.OutputFile != "" {
-		ctx.mu.Lock()
-		file, err := os.OpenFile(ctx.OutputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err == nil {
-			file.WriteString(str + "\n")
-			file.Close()
+// tagSummary lists tags by count, most successful first.
+func tagSummary(tags map[string]int64) string {
+	names := make([]string, 0, len(tags))
+	for tag := range tags {
+		names = append(names, tag)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if tags[names[i]] != tags[names[j]] {
+			return tags[names[i]] > tags[names[j]]
 		}
-		ctx.mu.Unlock()
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, len(names))
+	for i, tag := range names {
+		parts[i] = fmt.Sprintf("%s: %d", tag, tags[tag])
+	}
+	return strings.Join(parts, " - ")
+}
+
+func (ctx *Ctx) ClaimUnique(key string) bool {
+	if _, loaded := ctx.seen.LoadOrStore(key, struct{}{}); loaded {
+		atomic.AddInt64(&ctx.DuplicateCount, 1)
+		return false
+	}
+	return true
+}
+
+// Context is cancelled when the scan is abandoned or the task outlives the
+// task timeout, so scan functions should derive their dial and handshake
+// contexts from it. It is safe to call on a
+// nil Ctx.
+func (ctx *Ctx) Context() context.Context {
+	if ctx == nil || ctx.base == nil {
+		return context.Background()
+	}
+	if ctx.task != nil {
+		return ctx.task
+	}
+	return ctx.base
+}
+
+// late reports whether the task holding ctx ran past the task timeout and
+// was already counted as done.
+func (ctx *Ctx) late() bool {
+	return ctx.abandoned != nil && ctx.abandoned.Load()
+}
+
+// Pace blocks until dest may be probed again under the pacing set with
+// SetPacing, or until the scan is abandoned. It is safe to call on a nil Ctx.
+func (ctx *Ctx) Pace(dest string) {
+	if ctx == nil || ctx.pacer == nil {
+		return
+	}
+	ctx.pacer.wait(dest, ctx.Context().Done())
+}
+
+// Retry marks the current attempt at host as a transient failure, so it is
+// scanned again after a backoff when retries are enabled.
+func (ctx *Ctx) Retry(host string) {
+	if ctx.late() {
+		return
 	}
+	ctx.retries.Store(host, struct{}{})
+}
 
-	atomic.AddInt64(&ctx.SuccessCount, 1)
+func (ctx *Ctx) takeRetry(host string) bool {
+	_, ok := ctx.retries.LoadAndDelete(host)
+	return ok
 }
 
 func New(threads int, scanFunc func(c *Ctx, host string)) *QueueScanner {
+	base, cancel := context.WithCancel(context.Background())
 	scanner := &QueueScanner{
 		threads:  threads,
 		scanFunc: scanFunc,
 		queue:    make(chan string, threads*2),
-		ctx:      &Ctx{},
+		ctx:      &Ctx{scanState: &scanState{threads: int64(threads), base: base, cancel: cancel}},
+		stop:     make(chan struct{}),
 	}
+	scanner.pauseCond = sync.NewCond(&scanner.pauseMu)
 
+	scanner.workers = threads
 	for i := 0; i < scanner.threads; i++ {
 		scanner.wg.Add(1)
 		go scanner.run()
@@ -125,52 +424,600 @@ func New(threads int, scanFunc func(c *Ctx, host string)) *QueueScanner {
 }
 
 func (qs *QueueScanner) SetOptions(hostList []string, outputFile string, statInterval float64) {
-	qs.ctx.hostList = hostList
+	qs.hosts = nil
+	qs.added = nil
+	qs.Add(hostList...)
 	qs.ctx.OutputFile = outputFile
 	qs.ctx.statInterval = int64(statInterval * 1e9)
 }
 
+// NormalizeHost trims an input line and rejects blanks, comments and entries
+// with embedded whitespace or control characters.
+func NormalizeHost(host string) (string, bool) {
+	host = strings.TrimSpace(host)
+	if host == "" || strings.HasPrefix(host, "#") {
+		return "", false
+	}
+
+	for _, r := range host {
+		if r <= ' ' || r == 0x7f {
+			return "", false
+		}
+	}
+
+	return strings.ToLower(host), true
+}
+
+// Add queues hosts for Start, skipping invalid entries and any host already
+// added, so merged file, CIDR and single-host inputs scan each target once.
+func (qs *QueueScanner) Add(hosts ...string) {
+	qs.AddPriority(0, hosts...)
+}
+
+// AddPriority queues hosts like Add, but Start hands out higher priorities
+// first. Adding a queued host again only ever raises its priority.
+func (qs *QueueScanner) AddPriority(priority int, hosts ...string) {
+	if qs.added == nil {
+		qs.added = make(map[string]int)
+	}
+
+	for _, host := range hosts {
+		host, ok := NormalizeHost(host)
+		if !ok {
+			continue
+		}
+		if current, dup := qs.added[host]; dup {
+			qs.added[host] = max(current, priority)
+			continue
+		}
+
+		qs.added[host] = priority
+		qs.hosts = append(qs.hosts, host)
+	}
+}
+
+// SetSource feeds the scanner from source after any added hosts, so hosts can
+// be streamed or generated lazily. Pass a negative total when the count isn't
+// known up front; progress then shows hosts queued so far. Streamed hosts are
+// normalized like Add but not deduplicated, which would mean keeping them all.
+func (qs *QueueScanner) SetSource(source iter.Seq[string], total int) {
+	qs.source = source
+	qs.sourceTotal = total
+}
+
+// FromChannel adapts a channel of hosts into a source for SetSource.
+func FromChannel(ch <-chan string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for host := range ch {
+			if !yield(host) {
+				return
+			}
+		}
+	}
+}
+
+// SetInterleave hands out added hosts round-robin across /24 subnets (and
+// parent domains), so workers don't all hit one network at once. Priorities
+// still come first; streamed sources keep their order.
+func (qs *QueueScanner) SetInterleave(interleave bool) {
+	qs.interleave = interleave
+}
+
+// AddWriter registers an extra destination for every successful result,
+// next to the output file. Writers are closed when Start returns.
+func (qs *QueueScanner) AddWriter(writer ResultWriter) {
+	qs.ctx.writers = append(qs.ctx.writers, writer)
+}
+
+// Stats is a point-in-time snapshot of the scan counters. Total is -1 while
+// a streamed source is still producing.
+type Stats struct {
+	Total      int64
+	Queued     int64
+	Completed  int64
+	Success    int64
+	Duplicates int64
+	Retries    int64
+	Active     int64
+}
+
+func (qs *QueueScanner) Stats() Stats {
+	return Stats{
+		Total:      atomic.LoadInt64(&qs.ctx.total),
+		Queued:     atomic.LoadInt64(&qs.ctx.queued),
+		Completed:  atomic.LoadInt64(&qs.ctx.ScanComplete),
+		Success:    atomic.LoadInt64(&qs.ctx.SuccessCount),
+		Duplicates: atomic.LoadInt64(&qs.ctx.DuplicateCount),
+		Retries:    atomic.LoadInt64(&qs.ctx.RetryCount),
+		Active:     atomic.LoadInt64(&qs.ctx.active),
+	}
+}
+
+// SetMultiline splits the live status over two lines, progress on the
+// first and throughput on the second.
+func (qs *QueueScanner) SetMultiline(multiline bool) {
+	qs.ctx.multiline = multiline
+}
+
+// SetFormat picks how ScanSuccess writes results to the output file:
+// "table" (the default), "json" or "csv".
+func (qs *QueueScanner) SetFormat(format string) {
+	qs.ctx.format = format
+}
+
+// SetRetries allows up to retries extra attempts per host, waiting backoff
+// before the first and doubling it each time.
+func (qs *QueueScanner) SetRetries(retries int, backoff time.Duration) {
+	qs.retries = retries
+	qs.backoff = backoff
+}
+
+// SetRate caps scan attempts, retries included, at rate per second across
+// all threads. A rate of zero or less leaves them unlimited.
+func (qs *QueueScanner) SetRate(rate float64) {
+	if rate <= 0 {
+		qs.limiter = nil
+		return
+	}
+	qs.limiter = newTokenBucket(rate, 1)
+}
+
+// SetPacing keeps at least interval, plus up to jitter at random, between
+// probes that Pace the same destination.
+func (qs *QueueScanner) SetPacing(interval time.Duration, jitter time.Duration) {
+	if interval <= 0 && jitter <= 0 {
+		qs.ctx.pacer = nil
+		return
+	}
+	qs.ctx.pacer = &destPacer{interval: interval, jitter: jitter, next: make(map[string]time.Time)}
+}
+
+// SetCheckpoint appends every completed host to path as it finishes, so an
+// interrupted scan can be resumed. The file is removed once the scan completes.
+func (qs *QueueScanner) SetCheckpoint(path string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	qs.checkpointPath = path
+	qs.checkpoint = file
+
+	return nil
+}
+
+// SetOnDone calls fn once each host is finished, after any retries.
+// Hosts abandoned by an interrupt are not reported.
+func (qs *QueueScanner) SetOnDone(fn func(host string)) {
+	qs.onDone = fn
+}
+
+func (qs *QueueScanner) markDone(host string) {
+	if qs.onDone != nil {
+		qs.onDone(host)
+	}
+
+	if qs.checkpoint == nil {
+		return
+	}
+
+	qs.checkpointMu.Lock()
+	qs.checkpoint.WriteString(host + "\n")
+	qs.checkpointMu.Unlock()
+}
+
+func (qs *QueueScanner) closeCheckpoint(completed bool) {
+	if qs.checkpoint == nil {
+		return
+	}
+
+	qs.checkpointMu.Lock()
+	defer qs.checkpointMu.Unlock()
+
+	qs.checkpoint.Close()
+	qs.checkpoint = nil
+
+	if completed {
+		os.Remove(qs.checkpointPath)
+	} else if qs.ctx.jsonOut != nil {
+		qs.ctx.emit("checkpoint", map[string]any{"path": qs.checkpointPath})
+	} else {
+		fmt.Printf("progress saved to %s\n", qs.checkpointPath)
+	}
+}
+
+// Stop ends the scan early the same way an interrupt does: no new hosts are
+// handed out and running probes get gracePeriod to finish. Safe to call more
+// than once and from any goroutine, including scan functions.
+func (qs *QueueScanner) Stop(reason string) {
+	qs.stopOnce.Do(func() {
+		qs.ctx.Log(fmt.Sprintf("%s, stopping: waiting up to %s for running probes", reason, gracePeriod))
+		close(qs.stop)
+		qs.pauseMu.Lock()
+		qs.pauseCond.Broadcast()
+		qs.pauseMu.Unlock()
+	})
+}
+
+// SetMaxSuccess stops the scan, as if interrupted, once n results have been
+// reported. Probes already running may still add a few more.
+func (qs *QueueScanner) SetMaxSuccess(n int) {
+	qs.ctx.maxSuccess = int64(n)
+	qs.ctx.onEnough = func() {
+		qs.Stop(fmt.Sprintf("found %d successful hosts", n))
+	}
+}
+
+// SetMaxRuntime stops the scan once it has run for d, as if interrupted.
+func (qs *QueueScanner) SetMaxRuntime(d time.Duration) {
+	qs.maxRuntime = d
+}
+
+// SetLogReplay keeps the last n Log messages and prints them again once the
+// scan ends, after the status line has stopped overwriting the screen.
+func (qs *QueueScanner) SetLogReplay(n int) {
+	qs.ctx.logRing = nil
+	if n > 0 {
+		qs.ctx.logRing = make([]string, n)
+	}
+}
+
+// SetTaskTimeout abandons a scan function still running after d so a host
+// that hangs can't hold a worker. The abandoned call's Context is cancelled
+// and anything it reports afterwards is dropped, and the host is left out of
+// the checkpoint so --resume tries it again.
+func (qs *QueueScanner) SetTaskTimeout(d time.Duration) {
+	qs.taskTimeout = d
+}
+
+// gracePeriod is how long an interrupted scan waits for running probes.
+const gracePeriod = 5 * time.Second
+
+func (qs *QueueScanner) stopped() bool {
+	select {
+	case <-qs.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (qs *QueueScanner) enqueue(host string) bool {
+	select {
+	case qs.queue <- host:
+		atomic.AddInt64(&qs.ctx.queued, 1)
+		return true
+	case <-qs.stop:
+		return false
+	}
+}
+
+// Start scans every queued host. On SIGINT or SIGTERM it stops handing out
+// hosts, gives running probes gracePeriod to finish, then cancels Context for
+// any still going and returns normally so callers can flush their output;
+// a second signal exits at once.
 func (qs *QueueScanner) Start() {
 	qs.ctx.startTime = nowNano()
-	hideCursor()
-	defer showCursor()
+	qs.ctx.hideCursor()
+	defer qs.ctx.showCursor()
+
+	if qs.ctx.OutputFile != "" {
+		writer, err := NewFileWriter(qs.ctx.OutputFile, qs.ctx.format)
+		if err != nil {
+			qs.ctx.Log(fmt.Sprintf("result output failed: %v", err))
+		} else {
+			qs.ctx.writers = append([]ResultWriter{writer}, qs.ctx.writers...)
+		}
+	}
+	defer qs.closeWriters()
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	restoreTerminal := qs.watchKeys(sigChan)
+	defer restoreTerminal()
+
+	if qs.ctx.tui != nil {
+		qs.ctx.openTUI()
+	}
 
 	go func() {
 		<-sigChan
-		showCursor()
-		atomic.StoreInt64(&qs.ctx.lastStatTime, 0)
-		qs.ctx.LogStat()
-		fmt.Println()
-		os.Exit(0)
+		qs.Stop("interrupted (interrupt again to quit now)")
+
+		<-sigChan
+		restoreTerminal()
+		if qs.ctx.tui != nil {
+			qs.ctx.closeTUI()
+		}
+		qs.ctx.showCursor()
+		qs.ctx.printStat(true)
+		qs.closeWriters()
+		qs.closeCheckpoint(false)
+		os.Exit(1)
 	}()
 
-	for _, host := range qs.ctx.hostList {
-		qs.queue <- host
+	if qs.maxRuntime > 0 {
+		timer := time.AfterFunc(qs.maxRuntime, func() {
+			qs.Stop(fmt.Sprintf("max runtime of %s reached", qs.maxRuntime))
+		})
+		defer timer.Stop()
 	}
+
+	// keep elapsed time and rate moving while hosts are slow or paused, and
+	// get buffered results onto disk
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	tickerDone := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				qs.ctx.LogStat()
+				qs.flushWriters()
+			case <-tickerDone:
+				return
+			}
+		}
+	}()
+
+	total := len(qs.hosts)
+	if qs.source != nil {
+		if qs.sourceTotal < 0 {
+			total = -1
+		} else {
+			total += qs.sourceTotal
+		}
+	}
+	atomic.StoreInt64(&qs.ctx.total, int64(total))
+	qs.ctx.emit("start", map[string]any{"total": total, "threads": qs.threads})
+
+	qs.feed()
 	close(qs.queue)
 
-	qs.wg.Wait()
+	if atomic.LoadInt64(&qs.ctx.total) < 0 {
+		atomic.StoreInt64(&qs.ctx.total, atomic.LoadInt64(&qs.ctx.queued))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		qs.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-qs.stop:
+		select {
+		case <-done:
+		case <-time.After(gracePeriod):
+			qs.ctx.cancel()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+			}
+		}
+	}
+	qs.ctx.cancel()
+	close(tickerDone)
+	restoreTerminal()
+
+	if qs.ctx.tui != nil {
+		qs.ctx.closeTUI()
+	}
+	if qs.ctx.jsonOut == nil {
+		qs.ctx.replayLog()
+	}
+	qs.ctx.printStat(true)
+
+	interrupted := qs.stopped()
+	tags := qs.Tags()
+	if qs.ctx.jsonOut != nil {
+		fields := qs.ctx.statFields()
+		fields["stopped_early"] = interrupted
+		if len(tags) > 0 {
+			fields["tags"] = tags
+		}
+		qs.ctx.emit("finish", fields)
+	} else if len(tags) > 0 {
+		fmt.Printf("successes: %s\n", tagSummary(tags))
+	}
+	if qs.ctx.jsonOut == nil && interrupted {
+		fmt.Printf(
+			"stopped early: %d of %d hosts scanned, %d successful\n",
+			atomic.LoadInt64(&qs.ctx.ScanComplete),
+			atomic.LoadInt64(&qs.ctx.total),
+			atomic.LoadInt64(&qs.ctx.SuccessCount),
+		)
+	}
+
+	qs.closeCheckpoint(!interrupted)
+}
+
+func (qs *QueueScanner) flushWriters() {
+	qs.ctx.mu.Lock()
+	defer qs.ctx.mu.Unlock()
+
+	for _, writer := range qs.ctx.writers {
+		if f, ok := writer.(flusher); ok {
+			f.Flush()
+		}
+	}
+}
+
+func (qs *QueueScanner) closeWriters() {
+	qs.ctx.mu.Lock()
+	defer qs.ctx.mu.Unlock()
+
+	for _, writer := range qs.ctx.writers {
+		if err := writer.Close(); err != nil {
+			qs.ctx.Log(fmt.Sprintf("result output failed: %v", err))
+		}
+	}
+	qs.ctx.writers = nil
+}
+
+func (qs *QueueScanner) feed() {
+	if qs.interleave {
+		qs.hosts = interleave(qs.hosts)
+	}
+	sort.SliceStable(qs.hosts, func(i, j int) bool {
+		return qs.added[qs.hosts[i]] > qs.added[qs.hosts[j]]
+	})
+
+	for _, host := range qs.hosts {
+		if !qs.enqueue(host) {
+			return
+		}
+	}
+
+	if qs.source == nil {
+		return
+	}
+
+	for host := range qs.source {
+		if host, ok := NormalizeHost(host); ok && !qs.enqueue(host) {
+			return
+		}
+	}
+}
+
+// SetThreads changes the number of workers while a scan runs. Extra workers
+// start at once; surplus ones exit after their current host.
+func (qs *QueueScanner) SetThreads(n int) {
+	n = max(n, 1)
+
+	qs.workersMu.Lock()
+	defer qs.workersMu.Unlock()
 
-	atomic.StoreInt64(&qs.ctx.lastStatTime, 0)
-	qs.ctx.LogStat()
-	fmt.Println()
+	atomic.StoreInt64(&qs.ctx.threads, int64(n))
+
+	// once every worker has exited the queue is drained, nothing to grow
+	for qs.workers > 0 && qs.workers < n {
+		qs.workers++
+		qs.wg.Add(1)
+		go qs.run()
+	}
+}
+
+// Threads returns the current target worker count.
+func (qs *QueueScanner) Threads() int {
+	return int(atomic.LoadInt64(&qs.ctx.threads))
+}
+
+// retire ends the calling worker when there are more than the target, or
+// unconditionally when done is set.
+func (qs *QueueScanner) retire(done bool) bool {
+	qs.workersMu.Lock()
+	defer qs.workersMu.Unlock()
+
+	if done || int64(qs.workers) > atomic.LoadInt64(&qs.ctx.threads) {
+		qs.workers--
+		return true
+	}
+	return false
 }
 
 func (qs *QueueScanner) run() {
 	defer qs.wg.Done()
 
 	for {
+		if qs.retire(false) {
+			return
+		}
+
 		host, ok := <-qs.queue
 		if !ok {
+			qs.retire(true)
 			break
 		}
 
-		qs.scanFunc(qs.ctx, host)
+		qs.waitIfPaused()
+
+		// drain what was queued before an interrupt without scanning it
+		if qs.stopped() {
+			continue
+		}
+
+		if qs.limiter != nil {
+			qs.limiter.take(qs.stop)
+
+			// the wait for a token can outlast a stop
+			if qs.stopped() {
+				continue
+			}
+		}
+
+		finished := true
+		for attempt := 0; ; attempt++ {
+			if attempt > 0 && qs.limiter != nil {
+				qs.limiter.take(qs.stop)
+				if qs.stopped() {
+					finished = false
+					break
+				}
+			}
+			atomic.AddInt64(&qs.ctx.active, 1)
+			returned := qs.scanTask(host)
+			atomic.AddInt64(&qs.ctx.active, -1)
+
+			if !returned {
+				// abandoned, so --resume should try it again
+				finished = false
+				break
+			}
+			if !qs.ctx.takeRetry(host) || attempt >= qs.retries {
+				break
+			}
+
+			atomic.AddInt64(&qs.ctx.RetryCount, 1)
+			select {
+			case <-time.After(qs.backoff << attempt):
+				continue
+			case <-qs.stop:
+				// leave it out of the checkpoint so --resume tries it again
+				finished = false
+			}
+			break
+		}
 
+		// a probe cut off by cancelling Context didn't really finish either
+		if finished && qs.ctx.base.Err() == nil {
+			qs.markDone(host)
+		}
 		atomic.AddInt64(&qs.ctx.ScanComplete, 1)
 		qs.ctx.LogStat()
 	}
 }
+
+// scanTask runs the scan function for host and reports whether it returned
+// before the task timeout.
+func (qs *QueueScanner) scanTask(host string) bool {
+	if qs.taskTimeout <= 0 {
+		qs.scanFunc(qs.ctx, host)
+		return true
+	}
+
+	taskCtx, cancel := context.WithCancel(qs.ctx.base)
+	defer cancel()
+	ctx := &Ctx{scanState: qs.ctx.scanState, task: taskCtx, abandoned: new(atomic.Bool)}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		qs.scanFunc(ctx, host)
+	}()
+
+	timer := time.NewTimer(qs.taskTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		ctx.abandoned.Store(true)
+		qs.ctx.Log(fmt.Sprintf("%s: still running after %s, abandoned", host, qs.taskTimeout))
+		return false
+	}
+}
@@ -0,0 +1,178 @@
+package queuescanner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setHosts queues hosts with the status line held back for the length of
+// a test.
+func setHosts(qs *QueueScanner, hosts ...string) {
+	qs.SetOptions(hosts, "", 3600)
+	qs.ctx.lastStatTime = nowNano()
+}
+
+// runQueued feeds the queued hosts to qs's workers the way Start does and
+// waits for them to finish, without Start's signal handling and output.
+func runQueued(qs *QueueScanner) {
+	qs.feed()
+	close(qs.queue)
+	qs.wg.Wait()
+}
+
+func runHosts(qs *QueueScanner, hosts ...string) {
+	setHosts(qs, hosts...)
+	runQueued(qs)
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Fields(string(data))
+}
+
+func TestRunTaskTimeoutCheckpoint(t *testing.T) {
+	late := make(chan bool, 1)
+	qs := New(1, func(c *Ctx, host string) {
+		if host != "slow" {
+			c.ScanSuccess(&Result{Host: host})
+			return
+		}
+
+		select {
+		case <-c.Context().Done():
+		case <-time.After(5 * time.Second):
+			late <- false
+			return
+		}
+		c.ScanSuccess(&Result{Host: host})
+		c.Retry(host)
+		late <- true
+	})
+	qs.SetTaskTimeout(50 * time.Millisecond)
+	qs.SetRetries(3, time.Millisecond)
+
+	path := filepath.Join(t.TempDir(), "checkpoint.txt")
+	if err := qs.SetCheckpoint(path); err != nil {
+		t.Fatal(err)
+	}
+
+	runHosts(qs, "slow", "fast")
+
+	if cancelled := <-late; !cancelled {
+		t.Fatal("abandoned task's Context was never cancelled")
+	}
+	qs.closeCheckpoint(false)
+
+	if done := readLines(t, path); !slices.Equal(done, []string{"fast"}) {
+		t.Errorf("checkpoint = %v, want only the host that finished", done)
+	}
+	if n := atomic.LoadInt64(&qs.ctx.SuccessCount); n != 1 {
+		t.Errorf("SuccessCount = %d, want the abandoned task's late result dropped", n)
+	}
+	if n := atomic.LoadInt64(&qs.ctx.RetryCount); n != 0 {
+		t.Errorf("RetryCount = %d, want no retry of the abandoned task", n)
+	}
+	if n := atomic.LoadInt64(&qs.ctx.ScanComplete); n != 2 {
+		t.Errorf("ScanComplete = %d, want 2", n)
+	}
+}
+
+func TestRunRetries(t *testing.T) {
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	qs := New(2, func(c *Ctx, host string) {
+		mu.Lock()
+		attempts[host]++
+		n := attempts[host]
+		mu.Unlock()
+
+		switch {
+		case host == "flaky" && n == 1, host == "down":
+			c.Retry(host)
+		default:
+			c.ScanSuccess(&Result{Host: host})
+		}
+	})
+	qs.SetRetries(2, time.Millisecond)
+
+	path := filepath.Join(t.TempDir(), "checkpoint.txt")
+	if err := qs.SetCheckpoint(path); err != nil {
+		t.Fatal(err)
+	}
+
+	runHosts(qs, "ok", "flaky", "down")
+	qs.closeCheckpoint(false)
+
+	want := map[string]int{"ok": 1, "flaky": 2, "down": 3}
+	for host, n := range want {
+		if attempts[host] != n {
+			t.Errorf("%s scanned %d times, want %d", host, attempts[host], n)
+		}
+	}
+	if n := atomic.LoadInt64(&qs.ctx.RetryCount); n != 3 {
+		t.Errorf("RetryCount = %d, want 3", n)
+	}
+
+	// giving up after the last retry still finishes the host
+	done := readLines(t, path)
+	slices.Sort(done)
+	if !slices.Equal(done, []string{"down", "flaky", "ok"}) {
+		t.Errorf("checkpoint = %v, want every host", done)
+	}
+}
+
+func TestFeedOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		interleave bool
+		priority   []string
+		want       []string
+	}{
+		{
+			name: "input order",
+			want: []string{"a.x.com", "b.x.com", "c.y.com", "10.0.0.1", "10.0.0.2", "10.0.1.1"},
+		},
+		{
+			name:       "interleaved",
+			interleave: true,
+			want:       []string{"a.x.com", "c.y.com", "10.0.0.1", "10.0.1.1", "b.x.com", "10.0.0.2"},
+		},
+		{
+			name:     "priority first",
+			priority: []string{"10.0.0.2", "c.y.com"},
+			want:     []string{"c.y.com", "10.0.0.2", "a.x.com", "b.x.com", "10.0.0.1", "10.0.1.1"},
+		},
+		{
+			name:       "priority then interleaved",
+			interleave: true,
+			priority:   []string{"b.x.com"},
+			want:       []string{"b.x.com", "a.x.com", "c.y.com", "10.0.0.1", "10.0.1.1", "10.0.0.2"},
+		},
+	}
+
+	for _, test := range tests {
+		var order []string
+		qs := New(1, func(c *Ctx, host string) {
+			order = append(order, host)
+		})
+		qs.SetInterleave(test.interleave)
+		setHosts(qs, "a.x.com", "b.x.com", "c.y.com", "10.0.0.1", "10.0.0.2", "10.0.1.1")
+		qs.AddPriority(1, test.priority...)
+		runQueued(qs)
+
+		if !slices.Equal(order, test.want) {
+			t.Errorf("%s: scanned %v, want %v", test.name, order, test.want)
+		}
+	}
+}
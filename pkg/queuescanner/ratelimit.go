@@ -0,0 +1,155 @@
+package queuescanner
+
+import (
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucket hands out up to rate tokens per second, holding at most
+// capacity so idle time can't be saved up into a burst.
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64, capacity float64) *tokenBucket {
+	return &tokenBucket{
+		rate:     rate,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     time.Now(),
+	}
+}
+
+// take waits for a token, giving up early when stop closes.
+func (b *tokenBucket) take(stop <-chan struct{}) {
+	b.mu.Lock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+
+	// reserve the token now and sleep off the debt outside the lock
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+
+	b.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-stop:
+		}
+	}
+}
+
+// destPacer spaces out probes that share a destination, so several ports or
+// bugs on one IP don't arrive as a burst.
+type destPacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	jitter   time.Duration
+	next     map[string]time.Time
+	pruned   time.Time
+}
+
+// wait blocks until dest's next slot, giving up early when stop closes.
+func (p *destPacer) wait(dest string, stop <-chan struct{}) {
+	p.mu.Lock()
+
+	now := time.Now()
+
+	// a slot in the past says nothing a missing entry doesn't, so drop
+	// those now and then instead of keeping every destination of the scan
+	if now.Sub(p.pruned) > time.Minute {
+		for key, slot := range p.next {
+			if slot.Before(now) {
+				delete(p.next, key)
+			}
+		}
+		p.pruned = now
+	}
+
+	slot := p.next[dest]
+	if slot.Before(now) {
+		slot = now
+	}
+
+	gap := p.interval
+	if p.jitter > 0 {
+		gap += time.Duration(rand.Int63n(int64(p.jitter)))
+	}
+	p.next[dest] = slot.Add(gap)
+
+	p.mu.Unlock()
+
+	if delay := time.Until(slot); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-stop:
+		}
+	}
+}
+
+// subnetKey groups a task by its /24 (IPv4) or /64 (IPv6), or by its parent
+// domain for hostnames, which usually share a frontend too.
+func subnetKey(task string) string {
+	host := task
+	if h, _, err := net.SplitHostPort(task); err == nil {
+		host = h
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.Mask(net.CIDRMask(24, 32)).String()
+		}
+		return ip.Mask(net.CIDRMask(64, 128)).String()
+	}
+
+	if i := strings.IndexByte(host, '.'); i >= 0 && strings.Contains(host[i+1:], ".") {
+		return host[i+1:]
+	}
+	return host
+}
+
+// interleave reorders hosts round-robin across their subnets, keeping the
+// input order within each subnet.
+func interleave(hosts []string) []string {
+	var keys []string
+	groups := make(map[string][]string)
+	for _, host := range hosts {
+		key := subnetKey(host)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], host)
+	}
+
+	ordered := make([]string, 0, len(hosts))
+	for len(ordered) < len(hosts) {
+		for _, key := range keys {
+			if group := groups[key]; len(group) > 0 {
+				ordered = append(ordered, group[0])
+				groups[key] = group[1:]
+			}
+		}
+	}
+
+	return ordered
+}
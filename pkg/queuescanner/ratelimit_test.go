@@ -0,0 +1,130 @@
+package queuescanner
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(100, 1)
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		b.take(nil)
+	}
+	// the first token is there already, the other five come 10ms apart
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Errorf("6 tokens at 100/s took %s, want about 50ms", elapsed)
+	}
+
+	stop := make(chan struct{})
+	close(stop)
+	slow := newTokenBucket(0.1, 1)
+	slow.take(stop)
+
+	start = time.Now()
+	slow.take(stop)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("take after stop waited %s", elapsed)
+	}
+}
+
+func TestDestPacer(t *testing.T) {
+	p := &destPacer{interval: 30 * time.Millisecond, next: make(map[string]time.Time)}
+
+	start := time.Now()
+	p.wait("10.0.0.1", nil)
+	p.wait("10.0.0.2", nil)
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("first probes of two destinations took %s, want no wait", elapsed)
+	}
+
+	p.wait("10.0.0.1", nil)
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("second probe of a destination after %s, want the 30ms interval", elapsed)
+	}
+
+	stop := make(chan struct{})
+	close(stop)
+	p.next["10.0.0.3"] = time.Now().Add(time.Hour)
+	start = time.Now()
+	p.wait("10.0.0.3", stop)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("wait after stop took %s", elapsed)
+	}
+}
+
+func TestDestPacerPrune(t *testing.T) {
+	now := time.Now()
+	p := &destPacer{
+		interval: time.Millisecond,
+		next: map[string]time.Time{
+			"past":   now.Add(-time.Second),
+			"future": now.Add(time.Hour),
+		},
+	}
+
+	p.wait("new", nil)
+	if _, ok := p.next["past"]; ok {
+		t.Error("slot in the past was kept")
+	}
+	if _, ok := p.next["future"]; !ok {
+		t.Error("pending slot was pruned")
+	}
+	if _, ok := p.next["new"]; !ok {
+		t.Error("slot for the probed destination is missing")
+	}
+
+	// pruning waits a minute between sweeps
+	p.next["stale"] = time.Now().Add(-time.Second)
+	p.wait("other", nil)
+	if _, ok := p.next["stale"]; !ok {
+		t.Error("pruned again before a minute passed")
+	}
+}
+
+func TestSubnetKey(t *testing.T) {
+	tests := []struct {
+		task string
+		want string
+	}{
+		{"10.1.2.3", "10.1.2.0"},
+		{"10.1.2.3:443", "10.1.2.0"},
+		{"2001:db8:1:2:3::4", "2001:db8:1:2::"},
+		{"[2001:db8:1:2::9]:80", "2001:db8:1:2::"},
+		{"a.example.com", "example.com"},
+		{"b.a.example.com:8080", "a.example.com"},
+		{"example.com", "example.com"},
+		{"localhost", "localhost"},
+	}
+
+	for _, test := range tests {
+		if got := subnetKey(test.task); got != test.want {
+			t.Errorf("subnetKey(%q) = %q, want %q", test.task, got, test.want)
+		}
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	tests := []struct {
+		hosts []string
+		want  []string
+	}{
+		{nil, []string{}},
+		{
+			[]string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.1.1", "10.0.2.1"},
+			[]string{"10.0.0.1", "10.0.1.1", "10.0.2.1", "10.0.0.2", "10.0.0.3"},
+		},
+		{
+			[]string{"a.x.com", "b.x.com", "a.y.com", "b.y.com"},
+			[]string{"a.x.com", "a.y.com", "b.x.com", "b.y.com"},
+		},
+	}
+
+	for _, test := range tests {
+		if got := interleave(test.hosts); !slices.Equal(got, test.want) {
+			t.Errorf("interleave(%v) = %v, want %v", test.hosts, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package queuescanner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Result is one successful probe. Line is the row the command prints in its
+// own table layout; the other fields back the JSON and CSV renderings.
+type Result struct {
+	Host     string
+	IP       string
+	Port     string
+	Status   int
+	Server   string
+	Location string
+	Latency  time.Duration
+	Extra    map[string]string
+	Line     string
+}
+
+type jsonResult struct {
+	Host      string            `json:"host"`
+	IP        string            `json:"ip,omitempty"`
+	Port      string            `json:"port,omitempty"`
+	Status    int               `json:"status,omitempty"`
+	Server    string            `json:"server,omitempty"`
+	Location  string            `json:"location,omitempty"`
+	LatencyMs int64             `json:"latency_ms"`
+	Extra     map[string]string `json:"extra,omitempty"`
+}
+
+// CSVHeader names the columns of results rendered as "csv".
+const CSVHeader = "host,ip,port,status,server,location,latency_ms,extra"
+
+// Render formats the result as "table" (the command's own line), "json" or "csv".
+func (r *Result) Render(format string) string {
+	switch format {
+	case "json":
+		data, _ := json.Marshal(jsonResult{
+			Host:      r.Host,
+			IP:        r.IP,
+			Port:      r.Port,
+			Status:    r.Status,
+			Server:    r.Server,
+			Location:  r.Location,
+			LatencyMs: r.Latency.Milliseconds(),
+			Extra:     r.Extra,
+		})
+		return string(data)
+	case "csv":
+		status := ""
+		if r.Status != 0 {
+			status = strconv.Itoa(r.Status)
+		}
+		return csvLine([]string{r.Host, r.IP, r.Port, status, r.Server, r.Location, strconv.FormatInt(r.Latency.Milliseconds(), 10), r.extraString()})
+	}
+	return r.Line
+}
+
+func (r *Result) extraString() string {
+	keys := make([]string, 0, len(r.Extra))
+	for key := range r.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + r.Extra[key]
+	}
+	return strings.Join(pairs, ";")
+}
+
+func csvLine(fields []string) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write(fields)
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,187 @@
+package queuescanner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// tuiKeep is how many log lines the TUI holds for scrolling back through
+// with a filter.
+const tuiKeep = 5000
+
+// tuiRedraw limits how often a burst of Log calls repaints the screen.
+const tuiRedraw = 100 * time.Millisecond
+
+type tuiState struct {
+	header   []string
+	lines    []string
+	filter   string
+	editing  bool
+	lastDraw time.Time
+}
+
+func (t *tuiState) add(line string) {
+	if len(t.lines) >= tuiKeep {
+		t.lines = append(t.lines[:0], t.lines[len(t.lines)-tuiKeep/2:]...)
+	}
+	t.lines = append(t.lines, line)
+}
+
+// SetTUI switches to a full-screen view with a progress gauge, the latest
+// results and a filter typed after /. It needs stdin and stdout to be a
+// terminal; SetTUI reports whether the TUI was turned on.
+func (qs *QueueScanner) SetTUI(on bool) bool {
+	if !on || !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		qs.ctx.tui = nil
+		return false
+	}
+
+	qs.ctx.tui = &tuiState{header: qs.header}
+	return true
+}
+
+// SetHeader sets the column headings shown above the results in the TUI.
+func (qs *QueueScanner) SetHeader(lines ...string) {
+	qs.header = lines
+	if qs.ctx.tui != nil {
+		qs.ctx.tui.header = lines
+	}
+}
+
+func (ctx *Ctx) openTUI() {
+	fmt.Print("\033[?1049h\033[?25l")
+	ctx.printMu.Lock()
+	ctx.drawTUI(true)
+	ctx.printMu.Unlock()
+}
+
+// closeTUI leaves the alternate screen and prints what was logged so it
+// stays in the terminal scrollback like a normal run.
+func (ctx *Ctx) closeTUI() {
+	ctx.printMu.Lock()
+	defer ctx.printMu.Unlock()
+
+	fmt.Print("\033[?1049l")
+	for _, line := range ctx.tui.header {
+		fmt.Println(line)
+	}
+	for _, line := range ctx.tui.lines {
+		fmt.Println(line)
+	}
+	ctx.tui = nil
+}
+
+// tuiKey handles a key press in the TUI and reports whether it was used,
+// typing a filter takes every key until enter or escape.
+func (ctx *Ctx) tuiKey(key byte) bool {
+	ctx.printMu.Lock()
+	defer ctx.printMu.Unlock()
+
+	t := ctx.tui
+	if t == nil {
+		return false
+	}
+
+	switch {
+	case !t.editing && key == '/':
+		t.editing = true
+	case !t.editing:
+		return false
+	case key == '\r' || key == '\n':
+		t.editing = false
+	case key == 27: // escape
+		t.editing = false
+		t.filter = ""
+	case key == 127 || key == 8:
+		if t.filter != "" {
+			t.filter = t.filter[:len(t.filter)-1]
+		}
+	case key >= ' ' && key < 127:
+		t.filter += string(key)
+	default:
+		return true
+	}
+
+	ctx.drawTUI(true)
+	return true
+}
+
+// drawTUI repaints the screen, at most every tuiRedraw unless force is set.
+// The caller holds printMu.
+func (ctx *Ctx) drawTUI(force bool) {
+	t := ctx.tui
+	if t == nil || (!force && time.Since(t.lastDraw) < tuiRedraw) {
+		return
+	}
+	t.lastDraw = time.Now()
+
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+
+	rows := ctx.statLines()
+	rows = append(rows, ctx.gauge(width))
+	switch {
+	case t.editing:
+		rows = append(rows, "filter: "+t.filter+"_")
+	case t.filter != "":
+		rows = append(rows, "filter: "+t.filter+" - / edit, esc clear")
+	default:
+		rows = append(rows, "p pause - r resume - +/- threads - / filter - ctrl+c stop")
+	}
+	rows = append(rows, strings.Repeat("-", width))
+	rows = append(rows, t.header...)
+
+	var matching []string
+	for _, line := range t.lines {
+		if t.filter == "" || strings.Contains(strings.ToLower(line), strings.ToLower(t.filter)) {
+			matching = append(matching, line)
+		}
+	}
+	if room := height - len(rows); room < len(matching) {
+		matching = matching[len(matching)-max(room, 0):]
+	}
+	rows = append(rows, matching...)
+
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for i, row := range rows {
+		if i >= height {
+			break
+		}
+		if len(row) > width {
+			row = row[:width]
+		}
+		b.WriteString(row)
+		b.WriteString("\033[K")
+		if i < height-1 && i < len(rows)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	b.WriteString("\033[J")
+	fmt.Print(b.String())
+}
+
+// gauge draws a progress bar across width, or a count while streaming.
+func (ctx *Ctx) gauge(width int) string {
+	complete := atomic.LoadInt64(&ctx.ScanComplete)
+	total := atomic.LoadInt64(&ctx.total)
+	if total < 0 {
+		return fmt.Sprintf("[streaming, %d queued]", atomic.LoadInt64(&ctx.queued))
+	}
+
+	fraction := 1.0
+	if total > 0 {
+		fraction = float64(complete) / float64(total)
+	}
+
+	bar := max(width-9, 10)
+	filled := min(int(fraction*float64(bar)), bar)
+	return fmt.Sprintf("[%s%s] %5.1f%%", strings.Repeat("#", filled), strings.Repeat(".", bar-filled), fraction*100)
+}
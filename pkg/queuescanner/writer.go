@@ -0,0 +1,252 @@
+package queuescanner
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ResultWriter receives every result passed to ScanSuccess. Writers are
+// called one at a time, so implementations need no locking of their own.
+type ResultWriter interface {
+	WriteResult(result *Result) error
+	Close() error
+}
+
+// flusher is implemented by writers that buffer; the scanner flushes them
+// about once a second.
+type flusher interface {
+	Flush() error
+}
+
+// StreamWriter renders results onto an io.Writer such as os.Stdout.
+type StreamWriter struct {
+	w      io.Writer
+	format string
+}
+
+func NewStreamWriter(w io.Writer, format string) *StreamWriter {
+	return &StreamWriter{w: w, format: format}
+}
+
+func (s *StreamWriter) WriteResult(result *Result) error {
+	_, err := io.WriteString(s.w, result.Render(s.format)+"\n")
+	return err
+}
+
+func (s *StreamWriter) Close() error {
+	return nil
+}
+
+// FileWriter appends rendered results to a file kept open for the scan,
+// buffered so busy scans don't pay for a write call per result.
+type FileWriter struct {
+	StreamWriter
+	file *os.File
+	buf  *bufio.Writer
+}
+
+func NewFileWriter(path string, format string) (*FileWriter, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriterSize(file, 64*1024)
+	if format == "csv" {
+		// a file appended to by a resumed or repeated scan keeps the one header
+		if info, err := file.Stat(); err == nil && info.Size() == 0 {
+			buf.WriteString(CSVHeader + "\n")
+		}
+	}
+	return &FileWriter{StreamWriter: StreamWriter{w: buf, format: format}, file: file, buf: buf}, nil
+}
+
+func (f *FileWriter) Flush() error {
+	return f.buf.Flush()
+}
+
+func (f *FileWriter) Close() error {
+	flushErr := f.buf.Flush()
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+// SplitFileWriter keeps one FileWriter per path returned by pathFor, e.g.
+// one output file per port.
+type SplitFileWriter struct {
+	pathFor func(result *Result) string
+	format  string
+	files   map[string]*FileWriter
+}
+
+func NewSplitFileWriter(pathFor func(result *Result) string, format string) *SplitFileWriter {
+	return &SplitFileWriter{pathFor: pathFor, format: format, files: make(map[string]*FileWriter)}
+}
+
+func (s *SplitFileWriter) WriteResult(result *Result) error {
+	path := s.pathFor(result)
+	file, ok := s.files[path]
+	if !ok {
+		var err error
+		if file, err = NewFileWriter(path, s.format); err != nil {
+			return err
+		}
+		s.files[path] = file
+	}
+	return file.WriteResult(result)
+}
+
+func (s *SplitFileWriter) Flush() error {
+	var firstErr error
+	for _, file := range s.files {
+		if err := file.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (s *SplitFileWriter) Close() error {
+	var firstErr error
+	for path, file := range s.files {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.files, path)
+	}
+	return firstErr
+}
+
+// SinkWriter ships results as JSON to a collector: one POST per result for
+// http(s) URLs, otherwise newline-delimited JSON over a TCP connection.
+// Results are queued and sent from a goroutine of its own so a slow or dead
+// collector never holds up the scan; when the queue is full they're dropped.
+type SinkWriter struct {
+	target string
+	client *http.Client
+	conn   net.Conn
+
+	queue   chan Result
+	done    chan struct{}
+	dropped atomic.Int64
+
+	errMu    sync.Mutex
+	err      error
+	reported bool
+}
+
+const (
+	sinkQueueSize    = 1024
+	sinkDrainTimeout = 10 * time.Second
+)
+
+func NewSinkWriter(target string) *SinkWriter {
+	s := &SinkWriter{
+		target: target,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan Result, sinkQueueSize),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// WriteResult queues a copy of result. It returns the first delivery
+// failure once it has happened, and an error the first time one is dropped.
+func (s *SinkWriter) WriteResult(result *Result) error {
+	select {
+	case s.queue <- *result:
+	default:
+		if s.dropped.Add(1) == 1 {
+			return fmt.Errorf("sink: %s can't keep up, dropping results", s.target)
+		}
+	}
+
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	if s.err != nil && !s.reported {
+		s.reported = true
+		return s.err
+	}
+	return nil
+}
+
+func (s *SinkWriter) run() {
+	defer close(s.done)
+	for result := range s.queue {
+		if err := s.send(&result); err != nil {
+			s.errMu.Lock()
+			if s.err == nil {
+				s.err = err
+			}
+			s.errMu.Unlock()
+		}
+	}
+}
+
+func (s *SinkWriter) send(result *Result) error {
+	line := result.Render("json")
+
+	if strings.HasPrefix(s.target, "http://") || strings.HasPrefix(s.target, "https://") {
+		resp, err := s.client.Post(s.target, "application/json", bytes.NewBufferString(line))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("sink: unexpected status %s", resp.Status)
+		}
+		return nil
+	}
+
+	// reconnect once when the collector dropped the previous connection
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			conn, err := net.DialTimeout("tcp", s.target, 5*time.Second)
+			if err != nil {
+				return err
+			}
+			s.conn = conn
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		if _, err := io.WriteString(s.conn, line+"\n"); err == nil {
+			return nil
+		}
+
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	return fmt.Errorf("sink: write to %s failed", s.target)
+}
+
+// Close sends what is still queued, giving up after sinkDrainTimeout, and
+// reports how many results were dropped.
+func (s *SinkWriter) Close() error {
+	close(s.queue)
+	select {
+	case <-s.done:
+		if s.conn != nil {
+			s.conn.Close()
+			s.conn = nil
+		}
+	case <-time.After(sinkDrainTimeout):
+		return fmt.Errorf("sink: gave up on %d queued results for %s", len(s.queue), s.target)
+	}
+
+	if dropped := s.dropped.Load(); dropped > 0 {
+		return fmt.Errorf("sink: dropped %d results the collector couldn't keep up with", dropped)
+	}
+	return nil
+}
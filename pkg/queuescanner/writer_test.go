@@ -0,0 +1,91 @@
+package queuescanner
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSinkWriterTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+
+		var hosts []string
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			var result struct {
+				Host string `json:"host"`
+			}
+			json.Unmarshal(scanner.Bytes(), &result)
+			hosts = append(hosts, result.Host)
+		}
+		received <- hosts
+	}()
+
+	sink := NewSinkWriter(listener.Addr().String())
+	for _, host := range []string{"a.example", "b.example", "c.example"} {
+		if err := sink.WriteResult(&Result{Host: host}); err != nil {
+			t.Errorf("WriteResult(%s) error: %v", host, err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+
+	if hosts := <-received; !slices.Equal(hosts, []string{"a.example", "b.example", "c.example"}) {
+		t.Errorf("collector got %v, want every result in order", hosts)
+	}
+}
+
+func TestSinkWriterDeadCollector(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	sink := NewSinkWriter(address)
+	defer sink.Close()
+
+	if err := sink.WriteResult(&Result{Host: "a.example"}); err != nil {
+		t.Errorf("first WriteResult error: %v, want it queued", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sink.errMu.Lock()
+		failed := sink.err != nil
+		sink.errMu.Unlock()
+		if failed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("delivery to a closed port never failed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the failure surfaces on the next write, once
+	if err := sink.WriteResult(&Result{Host: "b.example"}); err == nil {
+		t.Error("WriteResult after a failed delivery returned no error")
+	}
+	if err := sink.WriteResult(&Result{Host: "c.example"}); err != nil {
+		t.Errorf("delivery error reported twice: %v", err)
+	}
+}
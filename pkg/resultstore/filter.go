@@ -0,0 +1,300 @@
+package resultstore
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filter is a parsed --where expression such as
+// status==200 && server=~"cloudflare" && latency<300ms
+type Filter struct {
+	root filterNode
+}
+
+type filterNode interface {
+	match(record Record) bool
+}
+
+type filterAnd struct{ left, right filterNode }
+type filterOr struct{ left, right filterNode }
+type filterNot struct{ node filterNode }
+
+type filterCompare struct {
+	field string
+	op    string
+	value string
+	regex *regexp.Regexp
+}
+
+func (n filterAnd) match(record Record) bool { return n.left.match(record) && n.right.match(record) }
+func (n filterOr) match(record Record) bool  { return n.left.match(record) || n.right.match(record) }
+func (n filterNot) match(record Record) bool { return !n.node.match(record) }
+
+func (f *Filter) Match(record Record) bool {
+	if f == nil || f.root == nil {
+		return true
+	}
+	return f.root.match(record)
+}
+
+func ParseFilter(expr string) (*Filter, error) {
+	if strings.TrimSpace(expr) == "" {
+		return &Filter{}, nil
+	}
+
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter", p.tokens[p.pos].text)
+	}
+
+	return &Filter{root: root}, nil
+}
+
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+var filterOperators = []string{"&&", "||", "==", "!=", "=~", "!~", "<=", ">=", "<", ">", "!", "(", ")"}
+
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+
+		if unicode.IsSpace(rune(c)) {
+			i++
+			continue
+		}
+
+		if c == '"' || c == '\'' {
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			tokens = append(tokens, filterToken{text: expr[i+1 : i+1+end], quoted: true})
+			i += end + 2
+			continue
+		}
+
+		matched := false
+		for _, op := range filterOperators {
+			if strings.HasPrefix(expr[i:], op) {
+				tokens = append(tokens, filterToken{text: op})
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		start := i
+		for i < len(expr) && !unicode.IsSpace(rune(expr[i])) && !strings.ContainsRune("&|=!<>()\"'", rune(expr[i])) {
+			i++
+		}
+		if start == i {
+			return nil, fmt.Errorf("unexpected %q in filter", expr[i:i+1])
+		}
+		tokens = append(tokens, filterToken{text: expr[start:i]})
+	}
+
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	switch p.peek() {
+	case "!":
+		p.pos++
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{node}, nil
+	case "(":
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing ) in filter")
+		}
+		p.pos++
+		return node, nil
+	}
+
+	return p.parseCompare()
+}
+
+func (p *filterParser) parseCompare() (filterNode, error) {
+	if p.pos+3 > len(p.tokens) {
+		return nil, fmt.Errorf("incomplete comparison in filter")
+	}
+
+	field, op, value := p.tokens[p.pos], p.tokens[p.pos+1], p.tokens[p.pos+2]
+	if field.quoted || op.quoted {
+		return nil, fmt.Errorf("expected field and operator in filter, got %q %q", field.text, op.text)
+	}
+
+	switch op.text {
+	case "==", "!=", "<", "<=", ">", ">=", "=~", "!~":
+	default:
+		return nil, fmt.Errorf("unknown operator %q in filter", op.text)
+	}
+	p.pos += 3
+
+	node := filterCompare{field: strings.ToLower(field.text), op: op.text, value: value.text}
+	if op.text == "=~" || op.text == "!~" {
+		regex, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q in filter: %w", value.text, err)
+		}
+		node.regex = regex
+	}
+
+	return node, nil
+}
+
+func (r Record) field(name string) (string, bool) {
+	switch name {
+	case "host":
+		return r.Host, true
+	case "ip":
+		return r.IP, true
+	case "port":
+		return r.Port, true
+	case "command":
+		return r.Command, true
+	case "run":
+		return r.Run, true
+	case "success":
+		return strconv.FormatBool(r.Success), true
+	case "latency":
+		return strconv.FormatInt(r.LatencyMs, 10), true
+	case "time":
+		return r.Time.Format(time.RFC3339), true
+	}
+
+	value, ok := r.Extra[name]
+	return value, ok
+}
+
+// filterNumber parses plain numbers and durations, durations in milliseconds.
+func filterNumber(value string) (float64, bool) {
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		return number, true
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return float64(duration) / float64(time.Millisecond), true
+	}
+	return 0, false
+}
+
+func (n filterCompare) match(record Record) bool {
+	if n.field == "tag" {
+		has := record.HasTag(n.value)
+		switch n.op {
+		case "==":
+			return has
+		case "!=":
+			return !has
+		}
+		for _, tag := range record.Tags {
+			if n.regex != nil && n.regex.MatchString(tag) {
+				return n.op == "=~"
+			}
+		}
+		return n.op == "!~"
+	}
+
+	actual, ok := record.field(n.field)
+	left, leftOK := filterNumber(actual)
+	right, rightOK := filterNumber(n.value)
+	numeric := ok && leftOK && rightOK
+
+	switch n.op {
+	case "==":
+		return ok && (actual == n.value || numeric && left == right)
+	case "!=":
+		return !ok || !(actual == n.value || numeric && left == right)
+	case "=~":
+		return ok && n.regex.MatchString(actual)
+	case "!~":
+		return !ok || !n.regex.MatchString(actual)
+	}
+
+	if !numeric {
+		return false
+	}
+
+	switch n.op {
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	default:
+		return left >= right
+	}
+}
@@ -0,0 +1,118 @@
+package resultstore
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFilterMatch(t *testing.T) {
+	record := Record{
+		Command:   "direct",
+		Host:      "example.com",
+		IP:        "104.16.1.1",
+		Port:      "443",
+		Success:   true,
+		LatencyMs: 120,
+		Extra:     map[string]string{"status": "200", "server": "cloudflare"},
+		Tags:      []string{"keep"},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{``, true},
+		{`status==200 && server=~"cloudflare" && latency<300ms`, true},
+		{`status==200 && server=~"cloudflare" && latency<100ms`, false},
+		{`latency<300ms`, true},
+		{`latency<0.1s`, false},
+		{`latency>=120`, true},
+		{`status!=200`, false},
+		{`status==404 || port==443`, true},
+		{`!(status==404 || port==80)`, true},
+		{`!status==200`, false},
+		{`(status==404 || port==443) && host=='example.com'`, true},
+		{`status==404 || port==443 && host=="other"`, false},
+		{`country!=US`, true},
+		{`country==US`, false},
+		{`country!~"^U"`, true},
+		{`country=~"."`, false},
+		{`country<10`, false},
+		{`server!~"akamai"`, true},
+		{`tag==keep`, true},
+		{`tag!=keep`, false},
+		{`tag=~"^ke"`, true},
+		{`success==true && command==direct`, true},
+	}
+
+	for _, test := range tests {
+		filter, err := ParseFilter(test.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q) error: %v", test.expr, err)
+			continue
+		}
+		if got := filter.Match(record); got != test.want {
+			t.Errorf("ParseFilter(%q).Match = %v, want %v", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`server=="cloud`, "unterminated string"},
+		{`(status==200 || port==443`, "missing )"},
+		{`server=~"("`, "invalid regex"},
+		{`status==`, "incomplete comparison"},
+		{`status 200`, "incomplete comparison"},
+		{`status && 200`, "unknown operator"},
+		{`"status"==200`, "expected field and operator"},
+		{`status==200 port==443`, "unexpected"},
+		{`status==200 &`, "unexpected"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseFilter(test.expr)
+		if err == nil {
+			t.Errorf("ParseFilter(%q) succeeded, want error containing %q", test.expr, test.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("ParseFilter(%q) error = %q, want it to contain %q", test.expr, err, test.want)
+		}
+	}
+}
+
+func TestTokenizeFilter(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []filterToken
+	}{
+		{
+			`status==200&&server=~"cloud flare"`,
+			[]filterToken{{text: "status"}, {text: "=="}, {text: "200"}, {text: "&&"}, {text: "server"}, {text: "=~"}, {text: "cloud flare", quoted: true}},
+		},
+		{
+			`!(latency <= 300ms)`,
+			[]filterToken{{text: "!"}, {text: "("}, {text: "latency"}, {text: "<="}, {text: "300ms"}, {text: ")"}},
+		},
+		{
+			`host!='a"b'`,
+			[]filterToken{{text: "host"}, {text: "!="}, {text: `a"b`, quoted: true}},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := tokenizeFilter(test.expr)
+		if err != nil {
+			t.Errorf("tokenizeFilter(%q) error: %v", test.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("tokenizeFilter(%q) = %v, want %v", test.expr, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package resultstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type Record struct {
+	Time      time.Time         `json:"time"`
+	Run       string            `json:"run"`
+	Command   string            `json:"command"`
+	Host      string            `json:"host"`
+	IP        string            `json:"ip,omitempty"`
+	Port      string            `json:"port,omitempty"`
+	Success   bool              `json:"success"`
+	LatencyMs int64             `json:"latency_ms,omitempty"`
+	Extra     map[string]string `json:"extra,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
+}
+
+func (r Record) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return &Store{path: path}, nil
+}
+
+func (s *Store) Path() string {
+	return s.path
+}
+
+func (s *Store) Append(records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+func (s *Store) Each(fn func(record Record) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func NewRunID() string {
+	return time.Now().UTC().Format("20060102T150405.000Z")
+}